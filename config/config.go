@@ -1,17 +1,557 @@
+// Package config loads the settings of the depository server. Values are
+// layered: defaults, then the YAML file given by -config or DEPOSITORY_CONFIG,
+// then DEPOSITORY_* environment variables, then command-line flags.
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/logging"
+	"gopkg.in/yaml.v3"
 )
 
-func getEnv(key string, defaultValue string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+var ErrInvalidConfig = errors.New("invalid config")
+
+type Config struct {
+	HTTP       HTTPConfig              `yaml:"http"`
+	Repository string                  `yaml:"repository"`
+	Database   DatabaseConfig          `yaml:"database"`
+	Auth       AuthConfig              `yaml:"auth"`
+	Exchange   ExchangeConfig          `yaml:"exchange"`
+	Limits     map[string]LimitsConfig `yaml:"limits"`
+	Notifier   NotifierConfig          `yaml:"notifier"`
+	Scheduler  SchedulerConfig         `yaml:"scheduler"`
+	Log        LogConfig               `yaml:"log"`
+	Tracing    TracingConfig           `yaml:"tracing"`
+}
+
+type HTTPConfig struct {
+	ListenAddress string        `yaml:"listen_address"`
+	ReadTimeout   time.Duration `yaml:"read_timeout"`
+	WriteTimeout  time.Duration `yaml:"write_timeout"`
+	IdleTimeout   time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout bounds how long in-flight requests are drained on
+	// SIGINT or SIGTERM.
+	ShutdownTimeout time.Duration   `yaml:"shutdown_timeout"`
+	RateLimit       RateLimitConfig `yaml:"rate_limit"`
+}
+
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// TrustProxy takes the client IP from the last X-Forwarded-For entry,
+	// which the proxy in front of the server appends. Only enable it behind
+	// exactly one such proxy, or clients can pick their own IP.
+	TrustProxy bool `yaml:"trust_proxy"`
+	// Routes maps a route name, "login", "transfer" or "password_reset", to
+	// its limits.
+	Routes map[string]RouteRateLimit `yaml:"routes"`
+}
+
+// RouteRateLimit limits a route per client IP and per target account.
+type RouteRateLimit struct {
+	IP      RateLimit `yaml:"ip"`
+	Account RateLimit `yaml:"account"`
+}
+
+// RateLimit allows Requests per Per; zero requests means unlimited.
+type RateLimit struct {
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+}
+
+type DatabaseConfig struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Address  string `yaml:"address"`
+	Name     string `yaml:"name"`
+}
+
+type AuthConfig struct {
+	SignKey           string        `yaml:"sign_key"`
+	AccessExpiration  time.Duration `yaml:"access_expiration"`
+	RefreshExpiration time.Duration `yaml:"refresh_expiration"`
+	Keys              []AuthKey     `yaml:"keys"`
+	ActiveKey         string        `yaml:"active_key"`
+	// AdminKey is a bearer token with the admin role, used to appoint the
+	// first admin accounts. It is disabled while empty.
+	AdminKey string         `yaml:"admin_key"`
+	Lockout  LockoutConfig  `yaml:"lockout"`
+	Password PasswordConfig `yaml:"password"`
+	MFA      MFAConfig      `yaml:"mfa"`
+}
+
+// MFAConfig configures TOTP two-factor authentication. TokenExpiration is how
+// long a login may take between password and code. Transfers of more than
+// TransferThreshold minor units out of an account with two-factor
+// authentication need a fresh code; zero never asks for one.
+type MFAConfig struct {
+	Issuer            string        `yaml:"issuer"`
+	TokenExpiration   time.Duration `yaml:"token_expiration"`
+	TransferThreshold int64         `yaml:"transfer_threshold"`
+}
+
+// PasswordConfig is the policy every new password must meet, and how long
+// password reset tokens can be used.
+type PasswordConfig struct {
+	MinLength     int           `yaml:"min_length"`
+	RequireUpper  bool          `yaml:"require_upper"`
+	RequireLower  bool          `yaml:"require_lower"`
+	RequireDigit  bool          `yaml:"require_digit"`
+	RequireSymbol bool          `yaml:"require_symbol"`
+	ResetTokenTTL time.Duration `yaml:"reset_token_ttl"`
+}
+
+// LockoutConfig locks an account after MaxFailures failed logins within
+// Window, first for BaseDuration and then twice as long each time, up to
+// MaxDuration. Zero MaxFailures disables locking.
+type LockoutConfig struct {
+	MaxFailures  int           `yaml:"max_failures"`
+	Window       time.Duration `yaml:"window"`
+	BaseDuration time.Duration `yaml:"base_duration"`
+	MaxDuration  time.Duration `yaml:"max_duration"`
+}
+
+// AuthKey describes a JWT signing key loaded from PEM files, or for HS256,
+// HS384 and HS512 from a file holding the shared secret.
+type AuthKey struct {
+	ID             string `yaml:"id"`
+	Algorithm      string `yaml:"algorithm"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+	SecretFile     string `yaml:"secret_file"`
+}
+
+func (k AuthKey) symmetric() bool {
+	return strings.HasPrefix(k.Algorithm, "HS")
+}
+
+type ExchangeConfig struct {
+	// RatesFile is a JSON file of static exchange rates; transfers between
+	// currencies are rejected when it is empty.
+	RatesFile string `yaml:"rates_file"`
+}
+
+// LimitsConfig holds the transfer limits of accounts in one currency that
+// have none of their own, in minor units of that currency. Zero means
+// unlimited.
+type LimitsConfig struct {
+	MaxSingleTransfer   int64 `yaml:"max_single_transfer"`
+	DailyOutgoing       int64 `yaml:"daily_outgoing"`
+	MonthlyOutgoing     int64 `yaml:"monthly_outgoing"`
+	MaxTransfersPerHour int   `yaml:"max_transfers_per_hour"`
+}
+
+type NotifierConfig struct {
+	// Kind is empty, which disables password resets, "log", which only logs
+	// that a message was sent, or "file", which appends messages with their
+	// reset tokens to File. Both are for development and need Dev.
+	Kind string `yaml:"kind"`
+	File string `yaml:"file"`
+	// Dev acknowledges that the notifier is not fit for production.
+	Dev bool `yaml:"dev"`
+}
+
+type SchedulerConfig struct {
+	// Interval is how often due scheduled transfers are executed.
+	Interval time.Duration `yaml:"interval"`
+}
+
+type LogConfig struct {
+	// Level is one of debug, info, warn or error.
+	Level string `yaml:"level"`
+}
+
+type TracingConfig struct {
+	// Exporter is "none", "stdout" or "otlp".
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the host:port of the OTLP/HTTP collector; the exporter
+	// falls back to OTEL_EXPORTER_OTLP_ENDPOINT when it is empty.
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+	ServiceName string  `yaml:"service_name"`
+}
+
+func Default() Config {
+	return Config{
+		HTTP: HTTPConfig{
+			ListenAddress:   ":8999",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+			RateLimit: RateLimitConfig{
+				Enabled: true,
+				Routes: map[string]RouteRateLimit{
+					"login": {
+						IP:      RateLimit{Requests: 20, Per: time.Minute},
+						Account: RateLimit{Requests: 5, Per: time.Minute},
+					},
+					"transfer": {
+						IP:      RateLimit{Requests: 60, Per: time.Minute},
+						Account: RateLimit{Requests: 30, Per: time.Minute},
+					},
+					"password_reset": {
+						IP:      RateLimit{Requests: 10, Per: time.Minute},
+						Account: RateLimit{Requests: 3, Per: time.Hour},
+					},
+				},
+			},
+		},
+		Repository: "postgres",
+		Database: DatabaseConfig{
+			User:     "postgres",
+			Password: "postgres",
+			Address:  "127.0.0.1:5432",
+			Name:     "depository",
+		},
+		Auth: AuthConfig{
+			AccessExpiration:  15 * time.Minute,
+			RefreshExpiration: 7 * 24 * time.Hour,
+			Lockout: LockoutConfig{
+				MaxFailures:  5,
+				Window:       15 * time.Minute,
+				BaseDuration: time.Minute,
+				MaxDuration:  24 * time.Hour,
+			},
+			Password: PasswordConfig{
+				MinLength:     8,
+				ResetTokenTTL: 30 * time.Minute,
+			},
+			MFA: MFAConfig{
+				Issuer:          "depository",
+				TokenExpiration: 5 * time.Minute,
+			},
+		},
+		Scheduler: SchedulerConfig{Interval: 30 * time.Second},
+		Log:       LogConfig{Level: "info"},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "depository",
+		},
+	}
+}
+
+// setting is a value that can be overridden by an environment variable and,
+// when flag is set, by a command-line flag. Secrets have no flag so they do
+// not end up in the process list.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+var settings = []setting{
+	{"DEPOSITORY_LISTEN_ADDRESS", "listen", "HTTP listen address", func(c *Config, v string) error {
+		c.HTTP.ListenAddress = v
+		return nil
+	}},
+	{"DEPOSITORY_SHUTDOWN_TIMEOUT", "shutdown-timeout", "how long in-flight requests are drained on shutdown", func(c *Config, v string) error {
+		return parseDuration(&c.HTTP.ShutdownTimeout, v)
+	}},
+	{"DEPOSITORY_REPOSITORY", "repository", `storage backend: "postgres" or "memory" for local development`, func(c *Config, v string) error {
+		c.Repository = v
+		return nil
+	}},
+	{"DEPOSITORY_DATABASE_USER", "database-user", "database user", func(c *Config, v string) error {
+		c.Database.User = v
+		return nil
+	}},
+	{"DEPOSITORY_DATABASE_PASS", "", "", func(c *Config, v string) error {
+		c.Database.Password = v
+		return nil
+	}},
+	{"DEPOSITORY_DATABASE_ADDRESS", "database-address", "database host:port", func(c *Config, v string) error {
+		c.Database.Address = v
+		return nil
+	}},
+	{"DEPOSITORY_DATABASE_DBNAME", "database-name", "database name", func(c *Config, v string) error {
+		c.Database.Name = v
+		return nil
+	}},
+	{"DEPOSITORY_AUTH_SIGN_KEY", "", "", func(c *Config, v string) error {
+		c.Auth.SignKey = v
+		return nil
+	}},
+	{"DEPOSITORY_AUTH_ADMIN_KEY", "", "", func(c *Config, v string) error {
+		c.Auth.AdminKey = v
+		return nil
+	}},
+	{"DEPOSITORY_AUTH_ACCESS_EXPIRATION", "access-expiration", "lifetime of access tokens", func(c *Config, v string) error {
+		return parseDuration(&c.Auth.AccessExpiration, v)
+	}},
+	{"DEPOSITORY_AUTH_REFRESH_EXPIRATION", "refresh-expiration", "lifetime of refresh tokens", func(c *Config, v string) error {
+		return parseDuration(&c.Auth.RefreshExpiration, v)
+	}},
+	{"DEPOSITORY_AUTH_KEYS", "auth-keys", "comma separated id:algorithm:private:public signing keys; HMAC keys take id:algorithm:secret-file", func(c *Config, v string) (err error) {
+		c.Auth.Keys, err = parseKeys(v)
+		return err
+	}},
+	{"DEPOSITORY_AUTH_ACTIVE_KEY", "active-key", "id of the key new tokens are signed with", func(c *Config, v string) error {
+		c.Auth.ActiveKey = v
+		return nil
+	}},
+	{"DEPOSITORY_EXCHANGE_RATES_FILE", "exchange-rates", "JSON file of static exchange rates", func(c *Config, v string) error {
+		c.Exchange.RatesFile = v
+		return nil
+	}},
+	{"DEPOSITORY_LOG_LEVEL", "log-level", "minimum level of logged records", func(c *Config, v string) error {
+		c.Log.Level = v
+		return nil
+	}},
+	{"DEPOSITORY_TRACE_EXPORTER", "trace-exporter", `span exporter: "none", "stdout" or "otlp"`, func(c *Config, v string) error {
+		c.Tracing.Exporter = v
+		return nil
+	}},
+	{"DEPOSITORY_TRACE_ENDPOINT", "trace-endpoint", "host:port of the OTLP/HTTP collector", func(c *Config, v string) error {
+		c.Tracing.Endpoint = v
+		return nil
+	}},
+	{"DEPOSITORY_MFA_TRANSFER_THRESHOLD", "mfa-transfer-threshold", "amount above which transfers need a fresh TOTP code; 0 never asks", func(c *Config, v string) (err error) {
+		c.Auth.MFA.TransferThreshold, err = strconv.ParseInt(v, 10, 64)
+		return err
+	}},
+	{"DEPOSITORY_NOTIFIER", "notifier", `development notifier of reset tokens: "log" or "file"; resets are disabled without one`, func(c *Config, v string) error {
+		c.Notifier.Kind = v
+		return nil
+	}},
+	{"DEPOSITORY_NOTIFIER_FILE", "notifier-file", "file the file notifier appends to", func(c *Config, v string) error {
+		c.Notifier.File = v
+		return nil
+	}},
+	{"DEPOSITORY_NOTIFIER_DEV", "notifier-dev", "allow the development notifiers", func(c *Config, v string) (err error) {
+		c.Notifier.Dev, err = strconv.ParseBool(v)
+		return err
+	}},
+	{"DEPOSITORY_SCHEDULER_INTERVAL", "scheduler-interval", "how often due scheduled transfers run", func(c *Config, v string) error {
+		return parseDuration(&c.Scheduler.Interval, v)
+	}},
+}
+
+// Load builds the configuration from args, which are the command-line
+// arguments without the program name, and checks it with validate, which is
+// Config.Validate for the server and Config.ValidateDatabase for commands
+// that only talk to the database. It returns the arguments left after the
+// flags.
+func Load(args []string, validate func(Config) error) (Config, []string, error) {
+	fs := flag.NewFlagSet("depository", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("DEPOSITORY_CONFIG"), "YAML configuration file")
+
+	flags := make(map[string]string)
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		name := s.flag
+		fs.Func(name, s.usage, func(v string) error {
+			flags[name] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	cfg := Default()
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return Config{}, nil, err
+		}
 	}
-	return defaultValue
+
+	for _, s := range settings {
+		if v := os.Getenv(s.env); v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				return Config{}, nil, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := flags[s.flag]; ok && s.flag != "" {
+			if err := s.set(&cfg, v); err != nil {
+				return Config{}, nil, fmt.Errorf("%w: -%s: %v", ErrInvalidConfig, s.flag, err)
+			}
+		}
+	}
+
+	if err := validate(cfg); err != nil {
+		return Config{}, nil, err
+	}
+
+	return cfg, fs.Args(), nil
 }
 
-var DatabaseUser = getEnv("DEPOSITORY_DATABASE_USER", "postgres")
-var DatabasePass = getEnv("DEPOSITORY_DATABASE_PASS", "postgres")
-var DatabaseAddress = getEnv("DEPOSITORY_DATABASE_ADDRESS", "127.0.0.1:5432")
-var DatabaseDBName = getEnv("DEPOSITORY_DATABASE_DBNAME", "depository")
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+	}
+
+	return nil
+}
+
+// ValidateDatabase reports the first setting that would keep a command that
+// only talks to the postgres database, like migrate, from running.
+func (c Config) ValidateDatabase() error {
+	if c.Database.Address == "" || c.Database.Name == "" {
+		return fmt.Errorf("%w: database address and name are required", ErrInvalidConfig)
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	return nil
+}
+
+// Validate reports the first setting that would keep the server from
+// starting correctly.
+func (c Config) Validate() error {
+	if c.HTTP.ListenAddress == "" {
+		return fmt.Errorf("%w: empty listen address", ErrInvalidConfig)
+	}
+	if c.HTTP.ReadTimeout <= 0 || c.HTTP.WriteTimeout <= 0 || c.HTTP.IdleTimeout <= 0 || c.HTTP.ShutdownTimeout <= 0 {
+		return fmt.Errorf("%w: HTTP timeouts must be positive", ErrInvalidConfig)
+	}
+
+	for route, limits := range c.HTTP.RateLimit.Routes {
+		for _, l := range []RateLimit{limits.IP, limits.Account} {
+			if l.Requests < 0 || (l.Requests > 0 && l.Per <= 0) {
+				return fmt.Errorf("%w: invalid rate limit of route %q", ErrInvalidConfig, route)
+			}
+		}
+	}
+
+	switch c.Repository {
+	case "memory":
+	case "postgres":
+		if c.Database.Address == "" || c.Database.Name == "" {
+			return fmt.Errorf("%w: database address and name are required", ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown repository %q", ErrInvalidConfig, c.Repository)
+	}
+
+	if c.Auth.SignKey == "" && len(c.Auth.Keys) == 0 {
+		return fmt.Errorf("%w: empty sign key", ErrInvalidConfig)
+	}
+	if c.Auth.AccessExpiration <= 0 {
+		return fmt.Errorf("%w: access expiration must be positive", ErrInvalidConfig)
+	}
+	if c.Auth.RefreshExpiration <= 0 {
+		return fmt.Errorf("%w: refresh expiration must be positive", ErrInvalidConfig)
+	}
+	if l := c.Auth.Lockout; l.MaxFailures < 0 || (l.MaxFailures > 0 && (l.Window <= 0 || l.BaseDuration <= 0 || l.MaxDuration < l.BaseDuration)) {
+		return fmt.Errorf("%w: invalid lockout policy", ErrInvalidConfig)
+	}
+	if p := c.Auth.Password; p.MinLength < 1 || p.MinLength > 72 || p.ResetTokenTTL <= 0 {
+		return fmt.Errorf("%w: password length must be between 1 and 72 and the reset token TTL positive", ErrInvalidConfig)
+	}
+	if m := c.Auth.MFA; m.Issuer == "" || strings.Contains(m.Issuer, ":") || m.TokenExpiration <= 0 || m.TransferThreshold < 0 {
+		return fmt.Errorf("%w: invalid mfa settings", ErrInvalidConfig)
+	}
+	for _, k := range c.Auth.Keys {
+		if k.ID == "" || k.Algorithm == "" {
+			return fmt.Errorf("%w: signing keys need an id and an algorithm", ErrInvalidConfig)
+		}
+		if k.symmetric() {
+			if k.SecretFile == "" || k.PrivateKeyFile != "" || k.PublicKeyFile != "" {
+				return fmt.Errorf("%w: key %q needs a secret file and no key files", ErrInvalidConfig, k.ID)
+			}
+			continue
+		}
+		if k.SecretFile != "" {
+			return fmt.Errorf("%w: key %q cannot use a secret file", ErrInvalidConfig, k.ID)
+		}
+		if k.PrivateKeyFile == "" && k.PublicKeyFile == "" {
+			return fmt.Errorf("%w: key %q has no key file", ErrInvalidConfig, k.ID)
+		}
+	}
+
+	for currency, l := range c.Limits {
+		if !entity.ValidCurrency(currency) {
+			return fmt.Errorf("%w: transfer limits for unsupported currency %q", ErrInvalidConfig, currency)
+		}
+		if l.MaxSingleTransfer < 0 || l.DailyOutgoing < 0 || l.MonthlyOutgoing < 0 || l.MaxTransfersPerHour < 0 {
+			return fmt.Errorf("%w: transfer limits of %s cannot be negative", ErrInvalidConfig, currency)
+		}
+	}
+
+	switch c.Notifier.Kind {
+	case "":
+	case "log", "file":
+		if !c.Notifier.Dev {
+			return fmt.Errorf("%w: the %s notifier is for development only and needs notifier.dev", ErrInvalidConfig, c.Notifier.Kind)
+		}
+		if c.Notifier.Kind == "file" && c.Notifier.File == "" {
+			return fmt.Errorf("%w: the file notifier needs a file", ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown notifier %q", ErrInvalidConfig, c.Notifier.Kind)
+	}
+
+	if c.Scheduler.Interval <= 0 {
+		return fmt.Errorf("%w: scheduler interval must be positive", ErrInvalidConfig)
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		return fmt.Errorf("%w: unknown trace exporter %q", ErrInvalidConfig, c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("%w: trace sample ratio must be between 0 and 1", ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+func parseDuration(d *time.Duration, v string) error {
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// parseKeys parses a comma separated list of id:algorithm:private:public
+// keys. Either file may be empty, e.g. "old:RS256::/keys/old.pub.pem". HMAC
+// keys are given as id:algorithm:secret-file, e.g. "k1:HS256:/keys/k1.secret".
+func parseKeys(v string) ([]AuthKey, error) {
+	var keys []AuthKey
+	for _, item := range strings.Split(v, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) == 3 {
+			key := AuthKey{ID: parts[0], Algorithm: parts[1], SecretFile: parts[2]}
+			if !key.symmetric() {
+				return nil, fmt.Errorf("invalid key %q", item)
+			}
+			keys = append(keys, key)
+			continue
+		}
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("invalid key %q", item)
+		}
+		keys = append(keys, AuthKey{ID: parts[0], Algorithm: parts[1], PrivateKeyFile: parts[2], PublicKeyFile: parts[3]})
+	}
+	return keys, nil
+}
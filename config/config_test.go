@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadLayersFileEnvAndFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "http:\n  listen_address: \":9000\"\nauth:\n  sign_key: secret\n  access_expiration: 5m\nrepository: memory\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DEPOSITORY_AUTH_ACCESS_EXPIRATION", "10m")
+	t.Setenv("DEPOSITORY_LISTEN_ADDRESS", ":9001")
+
+	cfg, rest, err := Load([]string{"-config", path, "-listen", ":9002", "up"}, Config.Validate)
+	if err != nil {
+		t.Fatalf("unexpected error while loading config: %s", err.Error())
+	}
+
+	if cfg.HTTP.ListenAddress != ":9002" {
+		t.Errorf("expected the flag to win, but got %q", cfg.HTTP.ListenAddress)
+	}
+	if cfg.Auth.AccessExpiration != 10*time.Minute {
+		t.Errorf("expected the env var to override the file, but got %s", cfg.Auth.AccessExpiration)
+	}
+	if cfg.Auth.RefreshExpiration != 7*24*time.Hour {
+		t.Errorf("expected the default refresh expiration, but got %s", cfg.Auth.RefreshExpiration)
+	}
+	if len(rest) != 1 || rest[0] != "up" {
+		t.Errorf("expected remaining args [up], but got %v", rest)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Default()
+	valid.Auth.SignKey = "secret"
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("unexpected error for a valid config: %v", err)
+	}
+
+	emptyKey := valid
+	emptyKey.Auth.SignKey = ""
+	negativeExpiry := valid
+	negativeExpiry.Auth.RefreshExpiration = -time.Minute
+	unknownRepo := valid
+	unknownRepo.Repository = "mysql"
+	devNotifier := valid
+	devNotifier.Notifier = NotifierConfig{Kind: "file", File: "notifications.jsonl"}
+	hmacKey := AuthKey{ID: "k1", Algorithm: "HS256", SecretFile: "/keys/k1.secret"}
+	secretKey := emptyKey
+	secretKey.Auth.Keys = []AuthKey{hmacKey}
+	noSecret := emptyKey
+	noSecret.Auth.Keys = []AuthKey{{ID: "k1", Algorithm: "HS256"}}
+	pemSecret := emptyKey
+	pemSecret.Auth.Keys = []AuthKey{{ID: "k1", Algorithm: "HS256", PrivateKeyFile: "/keys/k1.pem"}}
+	rsaSecret := emptyKey
+	rsaSecret.Auth.Keys = []AuthKey{{ID: "k1", Algorithm: "RS256", SecretFile: "/keys/k1.secret"}}
+
+	for name, cfg := range map[string]Config{
+		"empty sign key":           emptyKey,
+		"negative expiry":          negativeExpiry,
+		"unknown repository":       unknownRepo,
+		"notifier without dev":     devNotifier,
+		"hmac key without secret":  noSecret,
+		"hmac key with a pem file": pemSecret,
+		"rsa key with secret file": rsaSecret,
+	} {
+		if err := cfg.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, but got %v", name, err)
+		}
+	}
+
+	devNotifier.Notifier.Dev = true
+	if err := devNotifier.Validate(); err != nil {
+		t.Errorf("unexpected error for a development notifier: %v", err)
+	}
+	if err := secretKey.Validate(); err != nil {
+		t.Errorf("unexpected error for an hmac key with a secret file: %v", err)
+	}
+
+	keys, err := parseKeys("k1:HS256:/keys/k1.secret, old:RS256::/keys/old.pub.pem")
+	if err != nil {
+		t.Fatalf("unexpected error while parsing keys: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != hmacKey || keys[1].PublicKeyFile != "/keys/old.pub.pem" {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+
+	// migrations only need the database
+	if err := emptyKey.ValidateDatabase(); err != nil {
+		t.Errorf("unexpected error for a config without a sign key: %v", err)
+	}
+	noDatabase := emptyKey
+	noDatabase.Database.Name = ""
+	if err := noDatabase.ValidateDatabase(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig without a database name, but got %v", err)
+	}
+}
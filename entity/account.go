@@ -9,13 +9,19 @@ import (
 )
 
 type Account struct {
-	ID                uint64    `json:"id"`
-	FirstName         string    `json:"first_name"`
-	LastName          string    `json:"last_name"`
-	Number            int64     `json:"number"`
-	EncryptedPassword string    `json:"-"`
-	Balance           int64     `json:"balance"`
-	CreatedAt         time.Time `json:"created_at"`
+	ID                uint64        `json:"id"`
+	FirstName         string        `json:"first_name"`
+	LastName          string        `json:"last_name"`
+	Number            int64         `json:"number"`
+	EncryptedPassword string        `json:"-"`
+	Balance           int64         `json:"balance"`
+	Currency          string        `json:"currency"`
+	Role              Role          `json:"role"`
+	Status            AccountStatus `json:"status"`
+	CreatedAt         time.Time     `json:"created_at"`
+	// TokenVersion goes up with every password change; access tokens issued
+	// with an older version are no longer accepted.
+	TokenVersion int `json:"-"`
 }
 
 // TODO: can be replaced with uuid for Account's Number
@@ -28,7 +34,7 @@ func RandomNumber() int64 {
 }
 
 func NewAccount(fn, ln, password string, balance int64) (*Account, error) {
-	encpass, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	encpass, err := HashPassword(password)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create new account: %w", err)
 	}
@@ -38,9 +44,27 @@ func NewAccount(fn, ln, password string, balance int64) (*Account, error) {
 		ID:                id,
 		FirstName:         fn,
 		LastName:          ln,
-		EncryptedPassword: string(encpass),
+		EncryptedPassword: encpass,
 		Number:            n,
 		Balance:           balance,
+		Currency:          DefaultCurrency,
+		Role:              RoleCustomer,
+		Status:            StatusPending,
 		CreatedAt:         time.Now().UTC(),
 	}, nil
 }
+
+// HashPassword returns the bcrypt hash an account password is stored as.
+func HashPassword(password string) (string, error) {
+	encpass, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(encpass), nil
+}
+
+// PasswordMatches reports whether password hashes to hash. Repositories must
+// not compare the stored hash with the password itself.
+func PasswordMatches(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -11,7 +11,7 @@ func TestNewAccount(t *testing.T) {
 	ln := "Doe"
 	password := "mypassword"
 
-	account, err := NewAccount(fn, ln, password)
+	account, err := NewAccount(fn, ln, password, 0)
 
 	if err != nil {
 		t.Errorf("unexpected error while creating new account: %s", err.Error())
@@ -31,3 +31,18 @@ func TestNewAccount(t *testing.T) {
 		t.Errorf("expected account number to be between 10000000 and 99999999, but got %d", account.Number)
 	}
 }
+
+func TestPasswordMatchesComparesAgainstHash(t *testing.T) {
+	hash, err := HashPassword("mypassword")
+	if err != nil {
+		t.Fatalf("unexpected error while hashing password: %s", err.Error())
+	}
+
+	if !PasswordMatches(hash, "mypassword") {
+		t.Errorf("expected the password to match its hash")
+	}
+	// logins used to compare the given password with the stored hash
+	if PasswordMatches(hash, hash) {
+		t.Errorf("expected the hash itself not to be accepted as the password")
+	}
+}
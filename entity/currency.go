@@ -0,0 +1,36 @@
+package entity
+
+// DefaultCurrency is used for accounts created without a currency.
+const DefaultCurrency = "USD"
+
+// currencyExponents holds the number of minor units of the supported ISO 4217
+// currencies, e.g. 2 for USD where balances are kept in cents.
+var currencyExponents = map[string]int{
+	"AED": 2,
+	"AUD": 2,
+	"BHD": 3,
+	"CAD": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"INR": 2,
+	"IRR": 2,
+	"JPY": 0,
+	"KWD": 3,
+	"NOK": 2,
+	"SEK": 2,
+	"TRY": 2,
+	"USD": 2,
+}
+
+func ValidCurrency(code string) bool {
+	_, ok := currencyExponents[code]
+	return ok
+}
+
+// CurrencyExponent returns the number of minor units of the currency.
+func CurrencyExponent(code string) (int, bool) {
+	exp, ok := currencyExponents[code]
+	return exp, ok
+}
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// IdempotencyRecord remembers the request a key was first used with and the
+// response that was returned for it. Response is nil while the request is
+// still being processed. Keys are scoped to the account the money is sent
+// from, so two accounts may use the same key.
+type IdempotencyRecord struct {
+	FromAccount int64     `json:"from_account"`
+	Key         string    `json:"key"`
+	RequestHash string    `json:"request_hash"`
+	Response    []byte    `json:"response"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (r *IdempotencyRecord) Completed() bool {
+	return r.Response != nil
+}
@@ -0,0 +1,37 @@
+package entity
+
+// TransferLimits bounds the outgoing transfers of an account, in minor units
+// of its currency. A zero field sets no limit; on a per-account record it
+// falls back to the global limit of the account's currency.
+type TransferLimits struct {
+	Number              int64 `json:"number,omitempty"`
+	MaxSingleTransfer   int64 `json:"max_single_transfer"`
+	DailyOutgoing       int64 `json:"daily_outgoing"`
+	MonthlyOutgoing     int64 `json:"monthly_outgoing"`
+	MaxTransfersPerHour int   `json:"max_transfers_per_hour"`
+}
+
+// Override returns l with every limit set in o replaced by the one of o.
+func (l TransferLimits) Override(o TransferLimits) TransferLimits {
+	if o.MaxSingleTransfer != 0 {
+		l.MaxSingleTransfer = o.MaxSingleTransfer
+	}
+	if o.DailyOutgoing != 0 {
+		l.DailyOutgoing = o.DailyOutgoing
+	}
+	if o.MonthlyOutgoing != 0 {
+		l.MonthlyOutgoing = o.MonthlyOutgoing
+	}
+	if o.MaxTransfersPerHour != 0 {
+		l.MaxTransfersPerHour = o.MaxTransfersPerHour
+	}
+	l.Number = o.Number
+	return l
+}
+
+// TransferStats summarizes the outgoing transfers of an account over a
+// period.
+type TransferStats struct {
+	Count  int
+	Amount int64
+}
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// LoginLockout tracks the failed logins of an account. Failures counts the
+// consecutive failures since FirstFailureAt; Lockouts counts how often the
+// account was locked since its last successful login and makes every lock
+// last longer than the previous one.
+type LoginLockout struct {
+	Number         int64     `json:"number"`
+	Failures       int       `json:"failures"`
+	FirstFailureAt time.Time `json:"first_failure_at"`
+	Lockouts       int       `json:"lockouts"`
+	LockedUntil    time.Time `json:"locked_until"`
+}
+
+func (l LoginLockout) Locked(now time.Time) bool {
+	return now.Before(l.LockedUntil)
+}
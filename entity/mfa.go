@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// MFA is the TOTP second factor of an account. It only guards logins and
+// large transfers once Enabled, which happens when the first code generated
+// from Secret has been confirmed.
+type MFA struct {
+	Number int64 `json:"number"`
+	// Secret is the base32 encoded TOTP key.
+	Secret  string `json:"-"`
+	Enabled bool   `json:"enabled"`
+	// RecoveryCodes holds the hashes of the recovery codes not used yet.
+	RecoveryCodes []string `json:"-"`
+	// LastUsedStep is the TOTP time step of the last accepted code; codes of
+	// that step or earlier are not accepted again.
+	LastUsedStep int64     `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package entity
+
+// Role decides what an account may do besides using its own account.
+type Role string
+
+const (
+	// RoleCustomer only uses its own account.
+	RoleCustomer Role = "customer"
+	// RoleTeller posts deposits and withdrawals for any account and reads
+	// them.
+	RoleTeller Role = "teller"
+	// RoleAdmin manages accounts: freezing, deleting, roles and limits.
+	RoleAdmin Role = "admin"
+	// RoleAuditor reads everything and changes nothing.
+	RoleAuditor Role = "auditor"
+)
+
+func ValidRole(r Role) bool {
+	switch r {
+	case RoleCustomer, RoleTeller, RoleAdmin, RoleAuditor:
+		return true
+	}
+	return false
+}
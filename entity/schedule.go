@@ -0,0 +1,70 @@
+package entity
+
+import "time"
+
+type ScheduleFrequency string
+
+const (
+	FrequencyOnce    ScheduleFrequency = "once"
+	FrequencyDaily   ScheduleFrequency = "daily"
+	FrequencyWeekly  ScheduleFrequency = "weekly"
+	FrequencyMonthly ScheduleFrequency = "monthly"
+	FrequencyCron    ScheduleFrequency = "cron"
+)
+
+type ScheduleStatus string
+
+const (
+	ScheduleActive    ScheduleStatus = "active"
+	SchedulePaused    ScheduleStatus = "paused"
+	ScheduleCompleted ScheduleStatus = "completed"
+	ScheduleFailed    ScheduleStatus = "failed"
+	// ScheduleCancelled schedules were stopped because their account was
+	// closed.
+	ScheduleCancelled ScheduleStatus = "cancelled"
+)
+
+// ScheduledTransfer is a transfer executed once or repeatedly in the future.
+// NextRunAt is the occurrence that is due next and NextAttemptAt is when it is
+// tried, which moves forward while a failed occurrence is being retried.
+type ScheduledTransfer struct {
+	ID             string            `json:"id"`
+	FromAccount    int64             `json:"from_account"`
+	ToAccount      int64             `json:"to_account"`
+	Amount         int64             `json:"amount"`
+	Currency       string            `json:"currency"`
+	Reference      string            `json:"reference,omitempty"`
+	Frequency      ScheduleFrequency `json:"frequency"`
+	CronExpression string            `json:"cron_expression,omitempty"`
+	StartAt        time.Time         `json:"start_at"`
+	EndAt          time.Time         `json:"end_at,omitempty"`
+	Status         ScheduleStatus    `json:"status"`
+	NextRunAt      time.Time         `json:"next_run_at"`
+	NextAttemptAt  time.Time         `json:"next_attempt_at"`
+	Attempts       int               `json:"attempts"`
+	MaxAttempts    int               `json:"max_attempts"`
+	RetryBackoff   time.Duration     `json:"retry_backoff"`
+	LastRunAt      time.Time         `json:"last_run_at,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+type ExecutionStatus string
+
+const (
+	ExecutionSucceeded ExecutionStatus = "succeeded"
+	ExecutionFailed    ExecutionStatus = "failed"
+)
+
+// ScheduledTransferExecution records one attempt to execute an occurrence of
+// a scheduled transfer.
+type ScheduledTransferExecution struct {
+	ID         int64           `json:"id"`
+	ScheduleID string          `json:"schedule_id"`
+	RunAt      time.Time       `json:"run_at"`
+	Attempt    int             `json:"attempt"`
+	Status     ExecutionStatus `json:"status"`
+	TransferID string          `json:"transfer_id,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	ExecutedAt time.Time       `json:"executed_at"`
+}
@@ -0,0 +1,85 @@
+package entity
+
+import "time"
+
+// AccountStatus is the lifecycle state of an account. It decides whether
+// money can move in and out of the account.
+type AccountStatus string
+
+const (
+	// StatusPending accounts are opened but not activated yet; they can
+	// receive money but not send it, and become active when they first do.
+	StatusPending AccountStatus = "pending"
+	StatusActive  AccountStatus = "active"
+	// StatusFrozen accounts can neither send nor receive money.
+	StatusFrozen AccountStatus = "frozen"
+	// StatusDormant accounts have not been used for a long time; they can
+	// receive money but have to be reactivated before they send any.
+	StatusDormant AccountStatus = "dormant"
+	// StatusClosed accounts are only kept for their history. Closing requires
+	// a zero balance and cannot be undone.
+	StatusClosed AccountStatus = "closed"
+)
+
+// statusTransitions lists the statuses each status can change to.
+var statusTransitions = map[AccountStatus][]AccountStatus{
+	StatusPending: {StatusActive, StatusClosed},
+	StatusActive:  {StatusFrozen, StatusDormant, StatusClosed},
+	StatusFrozen:  {StatusActive, StatusClosed},
+	StatusDormant: {StatusActive, StatusFrozen, StatusClosed},
+	StatusClosed:  nil,
+}
+
+func ValidAccountStatus(s AccountStatus) bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// CanChangeTo reports whether an account can go from s to to.
+func (s AccountStatus) CanChangeTo(to AccountStatus) bool {
+	for _, next := range statusTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (s AccountStatus) CanSend() bool {
+	return s == StatusActive
+}
+
+func (s AccountStatus) CanReceive() bool {
+	return s == StatusActive || s == StatusPending || s == StatusDormant
+}
+
+// AccountStatusChange records who changed the status of an account and why.
+type AccountStatusChange struct {
+	ID     string        `json:"id"`
+	Number int64         `json:"number"`
+	From   AccountStatus `json:"from"`
+	To     AccountStatus `json:"to"`
+	Reason string        `json:"reason"`
+	// Actor is the account number of whoever made the change, "admin_key"
+	// when it was made with the admin key, or ActorSystem.
+	Actor     string    `json:"actor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ActorSystem is the Actor of the status changes the depository makes on its
+// own.
+const ActorSystem = "system"
+
+// NewActivation returns the change that activates a pending account when it
+// first receives money.
+func NewActivation(number int64, at time.Time) *AccountStatusChange {
+	return &AccountStatusChange{
+		ID:        NewID(),
+		Number:    number,
+		From:      StatusPending,
+		To:        StatusActive,
+		Reason:    "first funds received",
+		Actor:     ActorSystem,
+		CreatedAt: at,
+	}
+}
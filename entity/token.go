@@ -0,0 +1,49 @@
+package entity
+
+import "time"
+
+// RefreshToken is the stored form of an issued refresh token. Only the hash of
+// the token is kept. Tokens issued from the same login share a family, so
+// reusing a rotated token can revoke every descendant of it.
+type RefreshToken struct {
+	ID        string    `json:"id"`
+	FamilyID  string    `json:"family_id"`
+	Number    int64     `json:"number"`
+	TokenHash string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	RotatedAt time.Time `json:"rotated_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+func (t *RefreshToken) Rotated() bool {
+	return !t.RotatedAt.IsZero()
+}
+
+func (t *RefreshToken) Revoked() bool {
+	return !t.RevokedAt.IsZero()
+}
+
+func (t *RefreshToken) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// PasswordResetToken lets the holder of an account set a new password
+// without the old one. Only the hash of the token is kept and it can be used
+// once.
+type PasswordResetToken struct {
+	ID        string    `json:"id"`
+	Number    int64     `json:"number"`
+	TokenHash string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UsedAt    time.Time `json:"used_at"`
+}
+
+func (t *PasswordResetToken) Used() bool {
+	return !t.UsedAt.IsZero()
+}
+
+func (t *PasswordResetToken) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,190 @@
+package entity
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// ExternalAccountNumber is the counterpart of every movement of money that
+// enters or leaves the depository (opening balances, deposits, withdrawals),
+// so that the ledger stays balanced.
+const ExternalAccountNumber int64 = 0
+
+// LedgerEntry is one side of a transfer. Debits have a negative amount and
+// credits a positive one, both in minor units of Currency; Balance is the
+// balance of the account right after the entry has been applied.
+type LedgerEntry struct {
+	ID            int64     `json:"id"`
+	TransferID    string    `json:"transfer_id"`
+	AccountNumber int64     `json:"account_number"`
+	Amount        int64     `json:"amount"`
+	Currency      string    `json:"currency"`
+	Balance       int64     `json:"balance"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type TransferKind string
+
+const (
+	KindTransfer   TransferKind = "transfer"
+	KindDeposit    TransferKind = "deposit"
+	KindWithdrawal TransferKind = "withdrawal"
+)
+
+// MaxReferenceLength bounds the reference a client can attach to a transfer.
+const MaxReferenceLength = 140
+
+// Transfer groups the ledger entries of one movement of money. Rate is the
+// exchange rate applied when money moved between currencies.
+type Transfer struct {
+	ID        string        `json:"id"`
+	Kind      TransferKind  `json:"kind"`
+	Reference string        `json:"reference,omitempty"`
+	Entries   []LedgerEntry `json:"entries"`
+	Rate      string        `json:"rate,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	// Check, when set, is run by the repository while it holds the lock on
+	// the sending account and before any balance changes. outgoing reads the
+	// transfers that account sent in the same transaction, so concurrent
+	// transfers cannot all pass limits on what was sent before.
+	Check func(outgoing func(since time.Time) (TransferStats, error)) error `json:"-"`
+}
+
+// Sender returns the account the money of tr leaves, or ExternalAccountNumber
+// when it comes from outside the depository.
+func (tr *Transfer) Sender() int64 {
+	for _, e := range tr.Entries {
+		if e.Amount < 0 {
+			return e.AccountNumber
+		}
+	}
+	return ExternalAccountNumber
+}
+
+// NewID returns a random 32 character hex identifier.
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func NewTransferID() string {
+	return NewID()
+}
+
+// NewTransfer builds a transfer that debits from and credits to with amount.
+func NewTransfer(from, to, amount int64, currency string) *Transfer {
+	id := NewTransferID()
+	now := time.Now().UTC()
+
+	return &Transfer{
+		ID:   id,
+		Kind: KindTransfer,
+		Entries: []LedgerEntry{
+			{TransferID: id, AccountNumber: from, Amount: -amount, Currency: currency, CreatedAt: now},
+			{TransferID: id, AccountNumber: to, Amount: amount, Currency: currency, CreatedAt: now},
+		},
+		CreatedAt: now,
+	}
+}
+
+// NewDeposit builds a transfer that brings amount into the account from
+// outside the depository.
+func NewDeposit(number, amount int64, currency, reference string) *Transfer {
+	tr := NewTransfer(ExternalAccountNumber, number, amount, currency)
+	tr.Kind = KindDeposit
+	tr.Reference = reference
+	return tr
+}
+
+// NewWithdrawal builds a transfer that takes amount out of the depository.
+func NewWithdrawal(number, amount int64, currency, reference string) *Transfer {
+	tr := NewTransfer(number, ExternalAccountNumber, amount, currency)
+	tr.Kind = KindWithdrawal
+	tr.Reference = reference
+	return tr
+}
+
+// NewExchangeTransfer builds a transfer between accounts of different
+// currencies. The money is exchanged through the external account, so each
+// currency stays balanced on its own.
+func NewExchangeTransfer(from int64, amount int64, fromCurrency string, to int64, converted int64, toCurrency string, rate string) *Transfer {
+	id := NewTransferID()
+	now := time.Now().UTC()
+
+	return &Transfer{
+		ID:   id,
+		Kind: KindTransfer,
+		Entries: []LedgerEntry{
+			{TransferID: id, AccountNumber: from, Amount: -amount, Currency: fromCurrency, CreatedAt: now},
+			{TransferID: id, AccountNumber: ExternalAccountNumber, Amount: amount, Currency: fromCurrency, CreatedAt: now},
+			{TransferID: id, AccountNumber: ExternalAccountNumber, Amount: -converted, Currency: toCurrency, CreatedAt: now},
+			{TransferID: id, AccountNumber: to, Amount: converted, Currency: toCurrency, CreatedAt: now},
+		},
+		Rate:      rate,
+		CreatedAt: now,
+	}
+}
+
+// Sums returns the sum of the entries of the transfer per currency.
+func (t *Transfer) Sums() map[string]int64 {
+	sums := make(map[string]int64)
+	for _, e := range t.Entries {
+		sums[e.Currency] += e.Amount
+	}
+	return sums
+}
+
+// Balanced reports whether the transfer has at least a debit and a credit
+// and its entries sum to zero in every currency.
+func (t *Transfer) Balanced() bool {
+	if len(t.Entries) < 2 {
+		return false
+	}
+
+	for _, sum := range t.Sums() {
+		if sum != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type Direction string
+
+const (
+	DirectionIn  Direction = "in"
+	DirectionOut Direction = "out"
+)
+
+// Transaction is a ledger entry seen from the point of view of one account.
+type Transaction struct {
+	ID           int64        `json:"-"`
+	TransferID   string       `json:"transfer_id"`
+	Kind         TransferKind `json:"kind"`
+	Reference    string       `json:"reference,omitempty"`
+	Direction    Direction    `json:"direction"`
+	Amount       int64        `json:"amount"`
+	Currency     string       `json:"currency"`
+	Rate         string       `json:"rate,omitempty"`
+	Counterparty int64        `json:"counterparty"`
+	Balance      int64        `json:"balance"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// TransactionFilter selects the transactions of an account, newest first.
+// Zero values disable the corresponding filter; Before is the ledger entry ID
+// to continue from.
+type TransactionFilter struct {
+	Number    int64
+	Before    int64
+	From      time.Time
+	To        time.Time
+	Direction Direction
+	MinAmount int64
+	MaxAmount int64
+	Limit     int
+}
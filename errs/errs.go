@@ -0,0 +1,138 @@
+// Package errs declares the domain errors shared by the repository, service
+// and handler layers. Callers wrap them with context using fmt.Errorf("%w")
+// and match them with errors.Is; the handler maps their codes to HTTP status
+// codes.
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code is a stable, machine-readable identifier of an error.
+type Code string
+
+const (
+	CodeInternal               Code = "internal_error"
+	CodeInvalidRequest         Code = "invalid_request"
+	CodeMethodNotAllowed       Code = "method_not_allowed"
+	CodePermissionDenied       Code = "permission_denied"
+	CodeAccountNotFound        Code = "account_not_found"
+	CodeAccountExists          Code = "account_exists"
+	CodeInsufficientFunds      Code = "insufficient_funds"
+	CodeInvalidCredentials     Code = "invalid_credentials"
+	CodeSameAccount            Code = "same_account"
+	CodeInvalidAmount          Code = "invalid_amount"
+	CodeUnbalancedTransfer     Code = "unbalanced_transfer"
+	CodeInvalidCursor          Code = "invalid_cursor"
+	CodeIdempotencyKeyConflict Code = "idempotency_key_conflict"
+	CodeIdempotencyInProgress  Code = "idempotency_key_in_progress"
+	CodeNotFound               Code = "not_found"
+	CodeUnsupportedCurrency    Code = "unsupported_currency"
+	CodeCurrencyMismatch       Code = "currency_mismatch"
+	CodeRateUnavailable        Code = "rate_unavailable"
+	CodeInvalidToken           Code = "invalid_token"
+	CodeRateLimited            Code = "rate_limited"
+	CodeAccountLocked          Code = "account_locked"
+	CodeLimitExceeded          Code = "limit_exceeded"
+	CodeAccountFrozen          Code = "account_frozen"
+	CodeAccountClosed          Code = "account_closed"
+	CodeAccountInactive        Code = "account_inactive"
+	CodeInvalidStatusChange    Code = "invalid_status_change"
+	CodeBalanceNotZero         Code = "balance_not_zero"
+	CodeWeakPassword           Code = "weak_password"
+	CodeResetDisabled          Code = "password_reset_disabled"
+	CodeMFARequired            Code = "mfa_required"
+)
+
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	ErrInvalidRequest     = New(CodeInvalidRequest, "invalid request")
+	ErrMethodNotAllowed   = New(CodeMethodNotAllowed, "method not allowed")
+	ErrPermissionDenied   = New(CodePermissionDenied, "permission denied")
+	ErrAccountNotFound    = New(CodeAccountNotFound, "account with this number does not exist")
+	ErrAccountExists      = New(CodeAccountExists, "account with this number already exists")
+	ErrInsufficientFunds  = New(CodeInsufficientFunds, "insufficient balance")
+	ErrInvalidCredentials = New(CodeInvalidCredentials, "invalid account number or password")
+	ErrSameAccount        = New(CodeSameAccount, "cannot transfer to the same account")
+	ErrInvalidAmount      = New(CodeInvalidAmount, "amount must be positive")
+	ErrUnbalancedTransfer = New(CodeUnbalancedTransfer, "transfer entries do not sum to zero")
+	ErrInvalidCursor      = New(CodeInvalidCursor, "invalid cursor")
+
+	ErrInvalidRole = New(CodeInvalidRequest, "unknown role")
+
+	ErrAccountFrozen       = New(CodeAccountFrozen, "account is frozen")
+	ErrAccountClosed       = New(CodeAccountClosed, "account is closed")
+	ErrAccountInactive     = New(CodeAccountInactive, "account must be active to send money")
+	ErrInvalidStatus       = New(CodeInvalidRequest, "unknown account status")
+	ErrInvalidStatusChange = New(CodeInvalidStatusChange, "account cannot change to this status")
+	ErrBalanceNotZero      = New(CodeBalanceNotZero, "account balance must be zero to close it")
+
+	ErrUnsupportedCurrency = New(CodeUnsupportedCurrency, "unsupported currency")
+	ErrCurrencyMismatch    = New(CodeCurrencyMismatch, "currency does not match the currency of the account")
+	ErrRateUnavailable     = New(CodeRateUnavailable, "exchange rate is not available")
+
+	ErrAccountLocked   = New(CodeAccountLocked, "account is locked after too many failed logins")
+	ErrLockoutNotFound = New(CodeNotFound, "no failed logins on record")
+
+	ErrWeakPassword  = New(CodeWeakPassword, "password does not meet the password policy")
+	ErrResetDisabled = New(CodeResetDisabled, "password reset is not available")
+
+	ErrMFARequired       = New(CodeMFARequired, "a current two-factor code is required")
+	ErrInvalidMFACode    = New(CodeInvalidCredentials, "invalid two-factor code")
+	ErrMFANotEnrolled    = New(CodeNotFound, "two-factor authentication is not enrolled")
+	ErrMFAAlreadyEnabled = New(CodeInvalidRequest, "two-factor authentication is already enabled")
+
+	ErrInvalidToken = New(CodeInvalidToken, "token is invalid or expired")
+	ErrTokenReused  = New(CodeInvalidToken, "token has already been used; all sessions of this login are revoked")
+
+	ErrRateLimited = New(CodeRateLimited, "too many requests")
+
+	ErrLimitExceeded = New(CodeLimitExceeded, "transfer limit exceeded")
+
+	ErrScheduleNotFound = New(CodeNotFound, "scheduled transfer not found")
+	ErrInvalidSchedule  = New(CodeInvalidRequest, "invalid schedule")
+
+	ErrIdempotencyKeyConflict   = New(CodeIdempotencyKeyConflict, "idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = New(CodeIdempotencyInProgress, "a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyTooLong    = New(CodeInvalidRequest, "idempotency key is too long")
+	ErrIdempotencyKeyNotFound   = New(CodeNotFound, "idempotency key not found")
+	ErrIdempotencyKeyExists     = New(CodeIdempotencyKeyConflict, "idempotency key already exists")
+)
+
+// LimitError names the transfer rule that rejected a transfer. It matches
+// ErrLimitExceeded.
+type LimitError struct {
+	Rule  string
+	Limit int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s is limited to %d", ErrLimitExceeded.Message, e.Rule, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// CodeOf returns the code of the first *Error in the chain of err, or
+// CodeInternal if there is none.
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
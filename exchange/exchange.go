@@ -0,0 +1,60 @@
+// Package exchange converts amounts between currencies.
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+)
+
+// RateProvider returns how many units of currency to one unit of currency
+// from is worth.
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (*big.Rat, error)
+}
+
+// Convert converts amount, given in minor units of from, into minor units of
+// to. The result is rounded down, so an exchange never creates money.
+func Convert(amount int64, from, to string, rate *big.Rat) (int64, error) {
+	fromExp, ok := entity.CurrencyExponent(from)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", errs.ErrUnsupportedCurrency, from)
+	}
+	toExp, ok := entity.CurrencyExponent(to)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", errs.ErrUnsupportedCurrency, to)
+	}
+
+	converted := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), rate)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(toExp-fromExp))), nil))
+	if toExp > fromExp {
+		converted.Mul(converted, scale)
+	} else {
+		converted.Quo(converted, scale)
+	}
+
+	result := new(big.Int).Quo(converted.Num(), converted.Denom())
+	if !result.IsInt64() {
+		return 0, fmt.Errorf("%w: converted amount overflows", errs.ErrInvalidAmount)
+	}
+
+	return result.Int64(), nil
+}
+
+// FormatRate formats rate as a decimal without trailing zeros.
+func FormatRate(rate *big.Rat) string {
+	s := rate.FloatString(10)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
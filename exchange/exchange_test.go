@@ -0,0 +1,55 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/mohamadafzal06/depository/errs"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		from, to string
+		rate     string
+		want     int64
+	}{
+		{10000, "USD", "EUR", "0.92", 9200},
+		{10000, "USD", "JPY", "149.5", 14950},
+		{1000, "JPY", "USD", "0.0067", 670},
+		{10000, "USD", "KWD", "0.307", 30700},
+		{1, "USD", "EUR", "0.92", 0},
+	}
+
+	for _, tt := range tests {
+		rate, _ := new(big.Rat).SetString(tt.rate)
+		got, err := Convert(tt.amount, tt.from, tt.to, rate)
+		if err != nil {
+			t.Fatalf("unexpected error while converting: %s", err.Error())
+		}
+		if got != tt.want {
+			t.Errorf("expected %d %s to be %d %s, but got %d", tt.amount, tt.from, tt.want, tt.to, got)
+		}
+	}
+}
+
+func TestStaticProvider(t *testing.T) {
+	p, err := NewStaticProvider(map[string]map[string]string{"USD": {"EUR": "0.8"}})
+	if err != nil {
+		t.Fatalf("unexpected error while creating provider: %s", err.Error())
+	}
+
+	rate, err := p.Rate(context.Background(), "EUR", "USD")
+	if err != nil {
+		t.Fatalf("unexpected error while getting inverse rate: %s", err.Error())
+	}
+	if FormatRate(rate) != "1.25" {
+		t.Errorf("expected inverse rate to be 1.25, but got %s", FormatRate(rate))
+	}
+
+	if _, err := p.Rate(context.Background(), "USD", "GBP"); !errors.Is(err, errs.ErrRateUnavailable) {
+		t.Errorf("expected ErrRateUnavailable, but got %v", err)
+	}
+}
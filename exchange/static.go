@@ -0,0 +1,79 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+)
+
+// StaticProvider serves fixed rates. A missing pair is derived from its
+// inverse when that one is known.
+type StaticProvider struct {
+	rates map[string]map[string]*big.Rat
+}
+
+// NewStaticProvider builds a provider from decimal rates keyed by the source
+// and the target currency, e.g. rates["USD"]["EUR"] = "0.92".
+func NewStaticProvider(rates map[string]map[string]string) (*StaticProvider, error) {
+	p := &StaticProvider{rates: make(map[string]map[string]*big.Rat)}
+
+	for from, targets := range rates {
+		if !entity.ValidCurrency(from) {
+			return nil, fmt.Errorf("%w: %s", errs.ErrUnsupportedCurrency, from)
+		}
+		p.rates[from] = make(map[string]*big.Rat)
+
+		for to, value := range targets {
+			if !entity.ValidCurrency(to) {
+				return nil, fmt.Errorf("%w: %s", errs.ErrUnsupportedCurrency, to)
+			}
+
+			rate, ok := new(big.Rat).SetString(value)
+			if !ok || rate.Sign() <= 0 {
+				return nil, fmt.Errorf("invalid rate %s/%s: %q", from, to, value)
+			}
+			p.rates[from][to] = rate
+		}
+	}
+
+	return p, nil
+}
+
+// LoadStaticFile reads rates from a JSON file of the form
+//
+//	{"rates": {"USD": {"EUR": "0.92"}}}
+func LoadStaticFile(path string) (*StaticProvider, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read exchange rates file: %w", err)
+	}
+
+	var file struct {
+		Rates map[string]map[string]string `json:"rates"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("cannot parse exchange rates file: %w", err)
+	}
+
+	return NewStaticProvider(file.Rates)
+}
+
+func (p *StaticProvider) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	if rate, ok := p.rates[from][to]; ok {
+		return new(big.Rat).Set(rate), nil
+	}
+	if rate, ok := p.rates[to][from]; ok {
+		return new(big.Rat).Inv(rate), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s/%s", errs.ErrRateUnavailable, from, to)
+}
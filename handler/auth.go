@@ -1,74 +1,139 @@
 package handler
 
 import (
-	"fmt"
+	"context"
+	"crypto/subtle"
 	"net/http"
+	"strconv"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v4"
-	"github.com/mohamadafzal06/depository/param"
+	"github.com/gorilla/mux"
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
 	"github.com/mohamadafzal06/depository/service"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
-func permissioinDenied(w http.ResponseWriter) {
-	WriteJSON(w, http.StatusForbidden, HandlerErr{Error: "permissioin denied"})
-}
+// totpHeader carries the TOTP code of transfers that need a fresh one.
+const totpHeader = "X-TOTP-Code"
 
-func JWTMiddleware(hrFunc http.HandlerFunc, srv *service.Depository, authSrv *service.Auth, authCfg *service.AuthConfig) http.HandlerFunc {
-	fmt.Println("calling JWT auth middleware")
+func permissioinDenied(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, errs.ErrPermissionDenied)
+}
 
-	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
+// Policy declares who may call a route. With Owner set the account in the
+// request path may use the route; Roles may use it with any method and
+// ReadRoles only with GET. Authenticated lets every valid access token
+// through and leaves the decision to the handler, for routes that take the
+// account from the request body.
+type Policy struct {
+	Owner         bool
+	Roles         []entity.Role
+	ReadRoles     []entity.Role
+	Authenticated bool
+}
 
-		parts := strings.Split(authHeader, " ")
-		tokenString := parts[1]
+// Deposits and withdrawals move cash across the counter, so only staff may
+// make them; an owner moves money between accounts with a transfer.
+var (
+	ownerOrStaffRead = Policy{Owner: true, ReadRoles: []entity.Role{entity.RoleTeller, entity.RoleAdmin, entity.RoleAuditor}}
+	tellerOnly       = Policy{Roles: []entity.Role{entity.RoleTeller, entity.RoleAdmin}}
+	ownerOrAdmin     = Policy{Owner: true, Roles: []entity.Role{entity.RoleAdmin}}
+	adminOnly        = Policy{Roles: []entity.Role{entity.RoleAdmin}, ReadRoles: []entity.Role{entity.RoleAuditor}}
+	authenticated    = Policy{Authenticated: true}
+)
 
-		// token validation
-		token, err := validateJWT(tokenString, authCfg)
-		if err != nil {
-			permissioinDenied(w)
+func (p Policy) allows(r *http.Request, claims *service.Claims) bool {
+	if p.Authenticated {
+		return true
+	}
+	if p.Owner {
+		if number := getNumber(r); number != -1 && number == claims.Number {
+			return true
 		}
+	}
+	if claims.HasRole(p.Roles...) {
+		return true
+	}
+	return r.Method == http.MethodGet && claims.HasRole(p.ReadRoles...)
+}
 
-		if !token.Valid {
-			permissioinDenied(w)
-		}
+type claimsKey struct{}
 
-		var req param.GetAccountByNumberRequest
-		number := getNumber(r)
-		if number == -1 {
-			permissioinDenied(w)
-			return
-		}
+// claimsFrom returns the claims of the access token the request was
+// authorized with.
+func claimsFrom(ctx context.Context) *service.Claims {
+	claims, _ := ctx.Value(claimsKey{}).(*service.Claims)
+	return claims
+}
 
-		req.Number = number
+// actorOf names whoever the request was authorized for in audit records.
+func actorOf(ctx context.Context) string {
+	claims := claimsFrom(ctx)
+	if claims == nil {
+		return ""
+	}
+	if claims.Number == 0 {
+		return "admin_key"
+	}
+	return strconv.FormatInt(claims.Number, 10)
+}
 
-		account, err := srv.GetAccountByNumber(r.Context(), req)
-		if err != nil {
-			permissioinDenied(w)
+// authorized only lets requests through whose bearer token is an access token
+// that p allows. The configured admin key counts as a token of the admin
+// role, so the first admin can be appointed.
+func (h *Handler) authorized(p Policy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "authorize")
+		claims := h.authenticate(r)
+		if claims == nil || !p.allows(r, claims) {
+			span.SetStatus(codes.Error, "permission denied")
+			span.End()
+			permissioinDenied(w, r)
 			return
 		}
+		span.SetAttributes(attribute.String("enduser.role", string(claims.Role)))
+		span.End()
 
-		// parsing token for getting account number
-		claims, _ := authSrv.ParseToken(tokenString)
-		if account.Number != claims.Number {
-			permissioinDenied(w)
+		next(w, r.WithContext(context.WithValue(ctx, claimsKey{}, claims)))
+	}
+}
+
+// me serves the /me routes as if the account of the access token had been
+// given as {number} in the path.
+func (h *Handler) me(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := claimsFrom(r.Context())
+		// the admin key belongs to no account
+		if claims == nil || claims.Number == 0 {
+			permissioinDenied(w, r)
 			return
 		}
 
-		hrFunc(w, r)
+		next(w, mux.SetURLVars(r, map[string]string{"number": strconv.FormatInt(claims.Number, 10)}))
 	}
-
 }
 
-func validateJWT(tokenString string, cfg *service.AuthConfig) (*jwt.Token, error) {
-	secret := cfg.SignKey
+// authenticate returns the claims of the bearer token of r, or nil when it
+// has none or it is not a valid access token.
+func (h *Handler) authenticate(r *http.Request) *service.Claims {
+	authHeader := r.Header.Get("Authorization")
 
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil
+	}
+	tokenString := parts[1]
 
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
+	if h.authConfig.AdminKey != "" && subtle.ConstantTimeCompare([]byte(tokenString), []byte(h.authConfig.AdminKey)) == 1 {
+		return &service.Claims{Role: entity.RoleAdmin}
+	}
+
+	claims, err := h.auth.Authenticate(r.Context(), tokenString)
+	if err != nil {
+		return nil
+	}
 
-		return []byte(secret), nil
-	})
+	return claims
 }
@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+func TestRoutePolicies(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+
+	token := func(role entity.Role) (int64, string) {
+		acc, err := h.service.CreateAccount(ctx, param.CreateAccountRequest{FistName: "John", LastName: "Doe", Password: "mypassword", Balance: 100})
+		if err != nil {
+			t.Fatalf("unexpected error while creating account: %s", err.Error())
+		}
+		if err := h.service.SetAccountRole(ctx, param.SetAccountRoleRequest{Number: acc.Number, Role: role}); err != nil {
+			t.Fatalf("unexpected error while setting role: %s", err.Error())
+		}
+		login, err := h.auth.Login(ctx, param.LoginRequest{Number: acc.Number})
+		if err != nil {
+			t.Fatalf("unexpected error while logging in: %s", err.Error())
+		}
+		return acc.Number, login.TokenString
+	}
+	customer, customerToken := token(entity.RoleCustomer)
+	other, otherToken := token(entity.RoleCustomer)
+	_, tellerToken := token(entity.RoleTeller)
+	_, auditorToken := token(entity.RoleAuditor)
+	_, adminToken := token(entity.RoleAdmin)
+	num := func(n int64) string { return strconv.FormatInt(n, 10) }
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		token  string
+		want   int
+	}{
+		{"owner reads own account", http.MethodGet, "/account/" + num(customer), "", customerToken, http.StatusOK},
+		{"customer reads other account", http.MethodGet, "/account/" + num(customer), "", otherToken, http.StatusForbidden},
+		{"auditor reads any account", http.MethodGet, "/account/" + num(customer), "", auditorToken, http.StatusOK},
+		{"teller deposits to any account", http.MethodPost, "/account/" + num(customer) + "/deposit", `{"amount": 10}`, tellerToken, http.StatusOK},
+		{"owner cannot deposit to own account", http.MethodPost, "/account/" + num(customer) + "/deposit", `{"amount": 10}`, customerToken, http.StatusForbidden},
+		{"owner cannot withdraw from own account", http.MethodPost, "/account/" + num(customer) + "/withdraw", `{"amount": 10}`, customerToken, http.StatusForbidden},
+		{"auditor cannot deposit", http.MethodPost, "/account/" + num(customer) + "/deposit", `{"amount": 10}`, auditorToken, http.StatusForbidden},
+		{"customer cannot freeze", http.MethodPost, "/admin/account/" + num(other) + "/freeze", "", customerToken, http.StatusForbidden},
+		{"admin freezes account", http.MethodPost, "/admin/account/" + num(other) + "/freeze", "", adminToken, http.StatusOK},
+		{"transfer to frozen account", http.MethodPost, "/transfer", `{"from_account": ` + num(customer) + `, "to_account": ` + num(other) + `, "amount": 10}`, customerToken, http.StatusConflict},
+		{"transfer out of other account", http.MethodPost, "/transfer", `{"from_account": ` + num(other) + `, "to_account": ` + num(customer) + `, "amount": 10}`, customerToken, http.StatusForbidden},
+		{"admin cannot close funded account", http.MethodDelete, "/account/remove/" + num(other), `{"reason": "requested by customer"}`, adminToken, http.StatusConflict},
+		{"auditor reads status history", http.MethodGet, "/admin/account/" + num(other) + "/status", "", auditorToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		rec := httptest.NewRecorder()
+		h.server.Handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, but got %d: %s", tt.name, tt.want, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestMeTransferSendsFromTokenAccount(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+
+	var numbers []int64
+	for i := 0; i < 3; i++ {
+		acc, err := h.service.CreateAccount(ctx, param.CreateAccountRequest{FistName: "John", LastName: "Doe", Password: "mypassword", Balance: 100})
+		if err != nil {
+			t.Fatalf("unexpected error while creating account: %s", err.Error())
+		}
+		numbers = append(numbers, acc.Number)
+	}
+	login, err := h.auth.Login(ctx, param.LoginRequest{Number: numbers[0]})
+	if err != nil {
+		t.Fatalf("unexpected error while logging in: %s", err.Error())
+	}
+
+	// from_account names someone else's account and is ignored
+	body := fmt.Sprintf(`{"from_account": %d, "to_account": %d, "amount": 30}`, numbers[1], numbers[2])
+	req := httptest.NewRequest(http.MethodPost, "/me/transfer", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+login.TokenString)
+	rec := httptest.NewRecorder()
+	h.server.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, but got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for i, want := range []int64{70, 100, 130} {
+		acc, err := h.service.GetAccountByNumber(ctx, param.GetAccountByNumberRequest{Number: numbers[i]})
+		if err != nil {
+			t.Fatalf("unexpected error while getting account: %s", err.Error())
+		}
+		if acc.Balance != want {
+			t.Errorf("expected balance of account %d to be %d, but got %d", i, want, acc.Balance)
+		}
+	}
+}
+
+func TestAccessTokenRejectedOnceAccountFrozen(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+
+	acc, err := h.service.CreateAccount(ctx, param.CreateAccountRequest{FistName: "John", LastName: "Doe", Password: "mypassword", Balance: 100})
+	if err != nil {
+		t.Fatalf("unexpected error while creating account: %s", err.Error())
+	}
+	login, err := h.auth.Login(ctx, param.LoginRequest{Number: acc.Number})
+	if err != nil {
+		t.Fatalf("unexpected error while logging in: %s", err.Error())
+	}
+	me := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+login.TokenString)
+		rec := httptest.NewRecorder()
+		h.server.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := me(); code != http.StatusOK {
+		t.Fatalf("expected 200 before freezing, but got %d", code)
+	}
+	if err := h.service.FreezeAccount(ctx, param.FreezeAccountRequest{Number: acc.Number, Actor: "admin_key"}); err != nil {
+		t.Fatalf("unexpected error while freezing account: %s", err.Error())
+	}
+	if code := me(); code != http.StatusForbidden {
+		t.Errorf("expected token of frozen account to get 403, but got %d", code)
+	}
+}
+
+func TestAccessTokenRejectedAfterPasswordChange(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+
+	acc, err := h.service.CreateAccount(ctx, param.CreateAccountRequest{FistName: "John", LastName: "Doe", Password: "mypassword", Balance: 100})
+	if err != nil {
+		t.Fatalf("unexpected error while creating account: %s", err.Error())
+	}
+	before, err := h.auth.Login(ctx, param.LoginRequest{Number: acc.Number})
+	if err != nil {
+		t.Fatalf("unexpected error while logging in: %s", err.Error())
+	}
+	me := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.server.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// no waiting: tokens from the same second as the change must not survive it
+	if err := h.service.ChangePassword(ctx, param.ChangePasswordRequest{Number: acc.Number, CurrentPassword: "mypassword", NewPassword: "mynewpassword"}); err != nil {
+		t.Fatalf("unexpected error while changing password: %s", err.Error())
+	}
+	if code := me(before.TokenString); code != http.StatusForbidden {
+		t.Errorf("expected token issued before the password change to get 403, but got %d", code)
+	}
+	after, err := h.auth.Login(ctx, param.LoginRequest{Number: acc.Number})
+	if err != nil {
+		t.Fatalf("unexpected error while logging in: %s", err.Error())
+	}
+	if code := me(after.TokenString); code != http.StatusOK {
+		t.Errorf("expected token issued after the password change to get 200, but got %d", code)
+	}
+}
+
+func TestLoginAsksForSecondFactor(t *testing.T) {
+	h := newTestHandler(t)
+	ctx := context.Background()
+
+	acc, err := h.service.CreateAccount(ctx, param.CreateAccountRequest{FistName: "John", LastName: "Doe", Password: "mypassword"})
+	if err != nil {
+		t.Fatalf("unexpected error while creating account: %s", err.Error())
+	}
+	enrollment, err := h.service.EnrollMFA(ctx, param.EnrollMFARequest{Number: acc.Number})
+	if err != nil {
+		t.Fatalf("unexpected error while enrolling: %s", err.Error())
+	}
+
+	post := func(path, body string) (*httptest.ResponseRecorder, param.LoginResponse) {
+		rec := httptest.NewRecorder()
+		h.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		var resp param.LoginResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec, resp
+	}
+
+	// enrollment only takes effect once confirmed
+	if _, resp := post("/login", fmt.Sprintf(`{"number": %d, "password": "mypassword"}`, acc.Number)); resp.TokenString == "" {
+		t.Fatalf("expected unconfirmed enrollment to leave login alone, but got %+v", resp)
+	}
+	h.service.ConfirmMFA(ctx, param.MFACodeRequest{Number: acc.Number, Code: currentTOTP(t, enrollment.Secret)})
+
+	_, resp := post("/login", fmt.Sprintf(`{"number": %d, "password": "mypassword"}`, acc.Number))
+	if resp.Status != param.LoginMFARequired || resp.TokenString != "" || resp.MFAToken == "" {
+		t.Fatalf("expected only an MFA token, but got %+v", resp)
+	}
+
+	rec, _ := post("/login/mfa", fmt.Sprintf(`{"mfa_token": %q, "code": "000000"}`, resp.MFAToken))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a wrong code, but got %d", rec.Code)
+	}
+	rec, final := post("/login/mfa", fmt.Sprintf(`{"mfa_token": %q, "code": %q}`, resp.MFAToken, enrollment.RecoveryCodes[0]))
+	if rec.Code != http.StatusOK || final.TokenString == "" || final.RefreshToken == "" {
+		t.Errorf("expected tokens after the second factor, but got %d: %+v", rec.Code, final)
+	}
+}
+
+// currentTOTP computes the RFC 6238 code of secret for now.
+func currentTOTP(t *testing.T, secret string) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("unexpected error while decoding secret: %s", err.Error())
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:])&0x7fffffff)%1000000)
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/mohamadafzal06/depository/errs"
+)
+
+type HandlerErr struct {
+	Error string    `json:"error"`
+	Code  errs.Code `json:"code"`
+	// Rule names the transfer rule behind a limit_exceeded error.
+	Rule string `json:"rule,omitempty"`
+}
+
+var errInvalidNumber = fmt.Errorf("%w: the number is not valid", errs.ErrInvalidRequest)
+
+var statusByCode = map[errs.Code]int{
+	errs.CodeInvalidRequest:         http.StatusBadRequest,
+	errs.CodeMethodNotAllowed:       http.StatusMethodNotAllowed,
+	errs.CodePermissionDenied:       http.StatusForbidden,
+	errs.CodeAccountNotFound:        http.StatusNotFound,
+	errs.CodeAccountExists:          http.StatusConflict,
+	errs.CodeInsufficientFunds:      http.StatusUnprocessableEntity,
+	errs.CodeInvalidCredentials:     http.StatusUnauthorized,
+	errs.CodeSameAccount:            http.StatusBadRequest,
+	errs.CodeInvalidAmount:          http.StatusBadRequest,
+	errs.CodeUnbalancedTransfer:     http.StatusUnprocessableEntity,
+	errs.CodeInvalidCursor:          http.StatusBadRequest,
+	errs.CodeIdempotencyKeyConflict: http.StatusConflict,
+	errs.CodeIdempotencyInProgress:  http.StatusConflict,
+	errs.CodeNotFound:               http.StatusNotFound,
+	errs.CodeUnsupportedCurrency:    http.StatusBadRequest,
+	errs.CodeCurrencyMismatch:       http.StatusBadRequest,
+	errs.CodeRateUnavailable:        http.StatusUnprocessableEntity,
+	errs.CodeInvalidToken:           http.StatusUnauthorized,
+	errs.CodeRateLimited:            http.StatusTooManyRequests,
+	errs.CodeAccountLocked:          http.StatusLocked,
+	errs.CodeLimitExceeded:          http.StatusUnprocessableEntity,
+	errs.CodeAccountFrozen:          http.StatusConflict,
+	errs.CodeAccountClosed:          http.StatusConflict,
+	errs.CodeAccountInactive:        http.StatusConflict,
+	errs.CodeInvalidStatusChange:    http.StatusConflict,
+	errs.CodeBalanceNotZero:         http.StatusConflict,
+	errs.CodeWeakPassword:           http.StatusBadRequest,
+	errs.CodeResetDisabled:          http.StatusNotImplemented,
+	errs.CodeMFARequired:            http.StatusUnauthorized,
+}
+
+// writeError maps err to a status code and a machine-readable code. Errors
+// that are not domain errors are logged and reported as internal errors, so
+// their details never reach the client.
+func writeError(w http.ResponseWriter, r *http.Request, err error) error {
+	code := errs.CodeOf(err)
+	status, ok := statusByCode[code]
+	if !ok {
+		slog.ErrorContext(r.Context(), "internal error", "error", err, "method", r.Method, "path", r.URL.Path)
+		return WriteJSON(w, http.StatusInternalServerError, HandlerErr{Error: "internal error", Code: errs.CodeInternal})
+	}
+
+	body := HandlerErr{Error: err.Error(), Code: code}
+	var limitErr *errs.LimitError
+	if errors.As(err, &limitErr) {
+		body.Rule = limitErr.Rule
+	}
+
+	return WriteJSON(w, status, body)
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mohamadafzal06/depository/errs"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   errs.Code
+	}{
+		{fmt.Errorf("transfer money failed: %w", errs.ErrInsufficientFunds), http.StatusUnprocessableEntity, errs.CodeInsufficientFunds},
+		{fmt.Errorf("%w: 12345678", errs.ErrAccountNotFound), http.StatusNotFound, errs.CodeAccountNotFound},
+		{errs.ErrInvalidCredentials, http.StatusUnauthorized, errs.CodeInvalidCredentials},
+		{errs.ErrSameAccount, http.StatusBadRequest, errs.CodeSameAccount},
+		{errors.New("pq: connection refused"), http.StatusInternalServerError, errs.CodeInternal},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeError(rec, httptest.NewRequest(http.MethodGet, "/", nil), tt.err)
+
+		if rec.Code != tt.status {
+			t.Errorf("expected status %d for %v, but got %d", tt.status, tt.err, rec.Code)
+		}
+
+		var body HandlerErr
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("unexpected error while decoding body: %s", err.Error())
+		}
+		if body.Code != tt.code {
+			t.Errorf("expected code %s for %v, but got %s", tt.code, tt.err, body.Code)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// handleLiveness only reports that the process serves HTTP; it must not
+// depend on the database, or an outage would get every instance restarted.
+func (h *Handler) handleLiveness(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return errs.ErrMethodNotAllowed
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h *Handler) handleReadiness(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return errs.ErrMethodNotAllowed
+	}
+
+	if h.draining.Load() {
+		return WriteJSON(w, http.StatusServiceUnavailable, param.ReadinessResponse{Draining: true, Checks: map[string]string{}})
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	resp := h.service.Readiness(ctx)
+	if !resp.Ready {
+		return WriteJSON(w, http.StatusServiceUnavailable, resp)
+	}
+
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleVersion(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return errs.ErrMethodNotAllowed
+	}
+
+	return WriteJSON(w, http.StatusOK, buildVersion())
+}
+
+func buildVersion() param.VersionResponse {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return param.VersionResponse{Version: "unknown"}
+	}
+
+	resp := param.VersionResponse{Version: info.Main.Version, GoVersion: info.GoVersion}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			resp.Revision = s.Value
+		case "vcs.time":
+			resp.Time = s.Value
+		case "vcs.modified":
+			resp.Modified = s.Value == "true"
+		}
+	}
+
+	return resp
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mohamadafzal06/depository/config"
+	"github.com/mohamadafzal06/depository/metrics"
+	"github.com/mohamadafzal06/depository/ratelimit"
+	"github.com/mohamadafzal06/depository/repository/memory"
+	"github.com/mohamadafzal06/depository/service"
+)
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+
+	repo := memory.New()
+	auth, err := service.NewAuth(service.AuthConfig{
+		SignKey:               "secret",
+		AccessExpirationTime:  time.Minute,
+		RefreshExpirationTime: time.Hour,
+		AccessSubject:         "at",
+		RefreshSubject:        "rt",
+		MFASubject:            "mfa",
+		MFAExpirationTime:     time.Minute,
+	}, repo)
+	if err != nil {
+		t.Fatalf("unexpected error while creating auth: %s", err.Error())
+	}
+
+	return New(config.Default().HTTP, service.NewDepository(repo, nil), auth, metrics.New(), ratelimit.NewMemory())
+}
+
+func TestReadinessFailsWhileDraining(t *testing.T) {
+	h := newTestHandler(t)
+
+	rec := httptest.NewRecorder()
+	h.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected ready before shutdown, but got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if err := h.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error while shutting down: %s", err.Error())
+	}
+
+	rec = httptest.NewRecorder()
+	h.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while draining, but got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected liveness to stay healthy, but got %d", rec.Code)
+	}
+}
@@ -1,22 +1,28 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/mohamadafzal06/depository/config"
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/metrics"
 	"github.com/mohamadafzal06/depository/param"
+	"github.com/mohamadafzal06/depository/ratelimit"
 	"github.com/mohamadafzal06/depository/service"
 )
 
-type HandlerErr struct {
-	Error string `json:"error"`
-}
-
-func WriteJSON(w http.ResponseWriter, status int, v ...interface{}) error {
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) error {
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(status)
 
@@ -28,37 +34,108 @@ type apiFunc func(w http.ResponseWriter, r *http.Request) error
 func makeHTTPHandleFunc(f apiFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := f(w, r); err != nil {
-			WriteJSON(w, http.StatusBadRequest, HandlerErr{Error: err.Error()})
+			writeError(w, r, err)
 		}
 	}
 }
 
 type Handler struct {
-	listenAddr string
+	server     *http.Server
 	service    *service.Depository
 	auth       *service.Auth
 	authConfig *service.AuthConfig
+	metrics    *metrics.Metrics
+	limiter    ratelimit.Limiter
+	rateLimit  config.RateLimitConfig
+
+	// draining is set once Shutdown starts so readiness probes fail.
+	draining atomic.Bool
 }
 
-func New(lAddr string, srv *service.Depository) *Handler {
-	return &Handler{
-		listenAddr: lAddr,
+func New(cfg config.HTTPConfig, srv *service.Depository, auth *service.Auth, m *metrics.Metrics, limiter ratelimit.Limiter) *Handler {
+	authConfig := auth.Config()
+
+	h := &Handler{
 		service:    srv,
+		auth:       auth,
+		authConfig: &authConfig,
+		metrics:    m,
+		limiter:    limiter,
+		rateLimit:  cfg.RateLimit,
+	}
+	h.server = &http.Server{
+		Addr:         cfg.ListenAddress,
+		Handler:      h.routes(),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
 	}
+
+	return h
 }
 
-func (h *Handler) Run() {
-	router := http.NewServeMux()
+// Start serves requests until Shutdown is called. It returns nil after a
+// graceful shutdown.
+func (h *Handler) Start() error {
+	slog.Info("handler is running", "addr", h.server.Addr)
 
-	router.HandleFunc("/login", makeHTTPHandleFunc(h.handleLogin))
-	router.HandleFunc("/account", makeHTTPHandleFunc(h.handleAccount))
-	router.HandleFunc("/account/{number}", JWTMiddleware(makeHTTPHandleFunc(h.handleGetAccount), h.service, h.auth, h.authConfig))
-	router.HandleFunc("/account/remove/{number}", JWTMiddleware(makeHTTPHandleFunc(h.handleDeleteAccount), h.service, h.auth, h.authConfig))
-	router.HandleFunc("/transfer", JWTMiddleware(makeHTTPHandleFunc(h.handleTransfer), h.service, h.auth, h.authConfig))
+	if err := h.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
 
-	log.Printf("Handler is running on port: %s\n", h.listenAddr)
+// Shutdown stops accepting connections and waits for in-flight requests to
+// finish, or for ctx to be done.
+func (h *Handler) Shutdown(ctx context.Context) error {
+	h.draining.Store(true)
+	return h.server.Shutdown(ctx)
+}
 
-	http.ListenAndServe(h.listenAddr, router)
+func (h *Handler) routes() http.Handler {
+	router := mux.NewRouter()
+	router.Use(tracingMiddleware, requestLogger, h.metrics.Middleware)
+
+	router.Handle("/metrics", h.metrics.Handler())
+
+	router.HandleFunc("/healthz", makeHTTPHandleFunc(h.handleLiveness))
+	router.HandleFunc("/readyz", makeHTTPHandleFunc(h.handleReadiness))
+	router.HandleFunc("/version", makeHTTPHandleFunc(h.handleVersion))
+	router.HandleFunc("/login", h.rateLimited("login", makeHTTPHandleFunc(h.handleLogin)))
+	router.HandleFunc("/login/mfa", h.rateLimited("login", makeHTTPHandleFunc(h.handleLoginMFA)))
+	router.HandleFunc("/token/refresh", makeHTTPHandleFunc(h.handleRefreshToken))
+	router.HandleFunc("/logout", makeHTTPHandleFunc(h.handleLogout))
+	router.HandleFunc("/password/reset/request", h.rateLimited("password_reset", makeHTTPHandleFunc(h.handleRequestPasswordReset)))
+	router.HandleFunc("/password/reset", h.rateLimited("password_reset", makeHTTPHandleFunc(h.handleResetPassword)))
+	router.HandleFunc("/.well-known/jwks.json", makeHTTPHandleFunc(h.handleJWKS))
+	router.HandleFunc("/account", makeHTTPHandleFunc(h.handleAccount))
+	router.HandleFunc("/account/{number}", h.authorized(ownerOrStaffRead, makeHTTPHandleFunc(h.handleGetAccount)))
+	router.HandleFunc("/account/{number}/transactions", h.authorized(ownerOrStaffRead, makeHTTPHandleFunc(h.handleGetTransactions)))
+	router.HandleFunc("/account/{number}/deposit", h.authorized(tellerOnly, makeHTTPHandleFunc(h.handleDeposit)))
+	router.HandleFunc("/account/{number}/withdraw", h.authorized(tellerOnly, makeHTTPHandleFunc(h.handleWithdraw)))
+	router.HandleFunc("/account/{number}/schedules", h.authorized(ownerOrStaffRead, makeHTTPHandleFunc(h.handleSchedules)))
+	router.HandleFunc("/account/{number}/schedules/{id}", h.authorized(ownerOrStaffRead, makeHTTPHandleFunc(h.handleSchedule)))
+	router.HandleFunc("/account/{number}/schedules/{id}/pause", h.authorized(ownerOrStaffRead, makeHTTPHandleFunc(h.handlePauseSchedule)))
+	router.HandleFunc("/account/{number}/schedules/{id}/resume", h.authorized(ownerOrStaffRead, makeHTTPHandleFunc(h.handleResumeSchedule)))
+	router.HandleFunc("/account/{number}/schedules/{id}/executions", h.authorized(ownerOrStaffRead, makeHTTPHandleFunc(h.handleScheduleExecutions)))
+	router.HandleFunc("/account/{number}/limits", h.authorized(ownerOrStaffRead, makeHTTPHandleFunc(h.handleGetTransferLimits)))
+	router.HandleFunc("/account/remove/{number}", h.authorized(ownerOrAdmin, makeHTTPHandleFunc(h.handleCloseAccount)))
+	router.HandleFunc("/admin/account/{number}/unlock", h.authorized(adminOnly, makeHTTPHandleFunc(h.handleUnlockAccount)))
+	router.HandleFunc("/admin/account/{number}/limits", h.authorized(adminOnly, makeHTTPHandleFunc(h.handleTransferLimits)))
+	router.HandleFunc("/admin/account/{number}/freeze", h.authorized(adminOnly, makeHTTPHandleFunc(h.handleFreezeAccount)))
+	router.HandleFunc("/admin/account/{number}/unfreeze", h.authorized(adminOnly, makeHTTPHandleFunc(h.handleUnfreezeAccount)))
+	router.HandleFunc("/admin/account/{number}/role", h.authorized(adminOnly, makeHTTPHandleFunc(h.handleSetAccountRole)))
+	router.HandleFunc("/admin/account/{number}/status", h.authorized(adminOnly, makeHTTPHandleFunc(h.handleAccountStatus)))
+	router.HandleFunc("/transfer", h.rateLimited("transfer", h.authorized(authenticated, makeHTTPHandleFunc(h.handleTransfer))))
+	router.HandleFunc("/me", h.authorized(authenticated, h.me(makeHTTPHandleFunc(h.handleMe))))
+	router.HandleFunc("/me/transactions", h.authorized(authenticated, h.me(makeHTTPHandleFunc(h.handleGetTransactions))))
+	router.HandleFunc("/me/transfer", h.authorized(authenticated, h.me(h.rateLimited("transfer", makeHTTPHandleFunc(h.handleMeTransfer)))))
+	router.HandleFunc("/me/password", h.authorized(authenticated, h.me(makeHTTPHandleFunc(h.handleChangePassword))))
+	router.HandleFunc("/me/mfa", h.authorized(authenticated, h.me(makeHTTPHandleFunc(h.handleMFA))))
+	router.HandleFunc("/me/mfa/confirm", h.authorized(authenticated, h.me(makeHTTPHandleFunc(h.handleConfirmMFA))))
+
+	return router
 }
 
 func (s *Handler) handleAccount(w http.ResponseWriter, r *http.Request) error {
@@ -70,28 +147,26 @@ func (s *Handler) handleAccount(w http.ResponseWriter, r *http.Request) error {
 		return s.handleCreateAccount(w, r)
 	}
 	if r.Method == http.MethodDelete {
-		return s.handleDeleteAccount(w, r)
+		return s.handleCloseAccount(w, r)
 	}
 
-	return fmt.Errorf("method not allowed: %s", r.Method)
+	return fmt.Errorf("%w: %s", errs.ErrMethodNotAllowed, r.Method)
 }
 
 func (h *Handler) handleCreateAccount(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != http.MethodPost {
-		return fmt.Errorf("invalid method")
+		return errs.ErrMethodNotAllowed
 	}
 
 	var createdAccountReq param.CreateAccountRequest
 	err := json.NewDecoder(r.Body).Decode(&createdAccountReq)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("account creation failed."))
-		return fmt.Errorf("cannot bind requst body to request param: %w", err)
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
 	}
 
 	createdAccountResponse, err := h.service.CreateAccount(r.Context(), createdAccountReq)
 	if err != nil {
-		return WriteJSON(w, http.StatusInternalServerError, []byte("account creation failed."))
+		return err
 	}
 
 	return WriteJSON(w, http.StatusOK, createdAccountResponse)
@@ -99,97 +174,603 @@ func (h *Handler) handleCreateAccount(w http.ResponseWriter, r *http.Request) er
 
 func (h *Handler) handleGetAccount(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != http.MethodGet {
-		return fmt.Errorf("invalid method")
+		return errs.ErrMethodNotAllowed
 	}
 
 	var req param.GetAccountByNumberRequest
 	number := getNumber(r)
-	if number != -1 {
-		req.Number = getNumber(r)
-	} else {
-		return WriteJSON(w, http.StatusBadRequest, []byte("the number is not valid"))
+	if number == -1 {
+		return errInvalidNumber
 	}
+	req.Number = number
 
 	response, err := h.service.GetAccountByNumber(r.Context(), req)
 	if err != nil {
-		return WriteJSON(w, http.StatusInternalServerError, []byte("cannot get account with this number."))
+		return err
 	}
 
 	return WriteJSON(w, http.StatusOK, response)
 }
 
-func (h *Handler) handleDeleteAccount(w http.ResponseWriter, r *http.Request) error {
-	if r.Method != http.MethodDelete {
-		return fmt.Errorf("invalid method")
+func (h *Handler) handleMe(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == http.MethodGet {
+		return h.handleGetAccount(w, r)
 	}
+	if r.Method == http.MethodDelete {
+		return h.handleCloseAccount(w, r)
+	}
+
+	return fmt.Errorf("%w: %s", errs.ErrMethodNotAllowed, r.Method)
+}
 
-	var req param.DeleteAccountRequest
+// handleMFA enrolls the account in two-factor authentication on POST and
+// disables it on DELETE.
+func (h *Handler) handleMFA(w http.ResponseWriter, r *http.Request) error {
 	number := getNumber(r)
 
-	if number != -1 {
+	switch r.Method {
+	case http.MethodPost:
+		resp, err := h.service.EnrollMFA(r.Context(), param.EnrollMFARequest{Number: number})
+		if err != nil {
+			return err
+		}
+		return WriteJSON(w, http.StatusOK, resp)
+	case http.MethodDelete:
+		var req param.DisableMFARequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+		}
+		defer r.Body.Close()
 		req.Number = number
-	} else {
-		return WriteJSON(w, http.StatusBadRequest, []byte("the number is not valid"))
+
+		if err := h.service.DisableMFA(r.Context(), req); err != nil {
+			return err
+		}
+		return WriteJSON(w, http.StatusOK, map[string]string{"message": "two-factor authentication has been disabled."})
+	}
+
+	return fmt.Errorf("%w: %s", errs.ErrMethodNotAllowed, r.Method)
+}
+
+func (h *Handler) handleConfirmMFA(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+
+	var req param.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+	}
+	defer r.Body.Close()
+	req.Number = getNumber(r)
+
+	if err := h.service.ConfirmMFA(r.Context(), req); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "two-factor authentication has been enabled."})
+}
+
+func (h *Handler) handleChangePassword(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPut {
+		return errs.ErrMethodNotAllowed
+	}
+
+	var req param.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+	}
+	defer r.Body.Close()
+	req.Number = getNumber(r)
+
+	if err := h.service.ChangePassword(r.Context(), req); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "the password has been changed; please log in again."})
+}
+
+// handleCloseAccount closes the account rather than deleting it, so that its
+// history stays intact.
+func (h *Handler) handleCloseAccount(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodDelete {
+		return errs.ErrMethodNotAllowed
+	}
+
+	var req param.CloseAccountRequest
+	if err := decodeOptional(r, &req); err != nil {
+		return err
+	}
+	number := getNumber(r)
+
+	if number == -1 {
+		return errInvalidNumber
 	}
+	req.Number = number
+	req.Actor = actorOf(r.Context())
 
-	err := h.service.DeleteAccount(r.Context(), req)
+	err := h.service.CloseAccount(r.Context(), req)
 	if err != nil {
-		return WriteJSON(w, http.StatusInternalServerError, []byte("cannot delete account with this number."))
+		return err
 	}
 
-	return WriteJSON(w, http.StatusOK, []byte("the account has been removed successully."))
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "the account has been closed."})
+}
+
+func (h *Handler) handleUnlockAccount(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+
+	number := getNumber(r)
+	if number == -1 {
+		return errInvalidNumber
+	}
+
+	if err := h.service.UnlockAccount(r.Context(), param.UnlockAccountRequest{Number: number}); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "the account has been unlocked."})
+}
+
+func (h *Handler) handleGetTransferLimits(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return errs.ErrMethodNotAllowed
+	}
+
+	number := getNumber(r)
+	if number == -1 {
+		return errInvalidNumber
+	}
+
+	resp, err := h.service.GetTransferLimits(r.Context(), param.GetTransferLimitsRequest{Number: number})
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+// handleTransferLimits lets admins view and replace the transfer limits of an
+// account.
+func (h *Handler) handleTransferLimits(w http.ResponseWriter, r *http.Request) error {
+	if r.Method == http.MethodGet {
+		return h.handleGetTransferLimits(w, r)
+	}
+	if r.Method != http.MethodPut {
+		return errs.ErrMethodNotAllowed
+	}
+
+	number := getNumber(r)
+	if number == -1 {
+		return errInvalidNumber
+	}
+
+	var req param.UpdateTransferLimitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+	}
+	defer r.Body.Close()
+	req.Number = number
+
+	resp, err := h.service.UpdateTransferLimits(r.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleFreezeAccount(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+
+	number := getNumber(r)
+	if number == -1 {
+		return errInvalidNumber
+	}
+
+	var req param.FreezeAccountRequest
+	if err := decodeOptional(r, &req); err != nil {
+		return err
+	}
+	req.Number = number
+	req.Actor = actorOf(r.Context())
+
+	if err := h.service.FreezeAccount(r.Context(), req); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "the account has been frozen."})
+}
+
+func (h *Handler) handleUnfreezeAccount(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+
+	number := getNumber(r)
+	if number == -1 {
+		return errInvalidNumber
+	}
+
+	var req param.FreezeAccountRequest
+	if err := decodeOptional(r, &req); err != nil {
+		return err
+	}
+	req.Number = number
+	req.Actor = actorOf(r.Context())
+
+	if err := h.service.UnfreezeAccount(r.Context(), req); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "the account has been unfrozen."})
+}
+
+// handleAccountStatus returns the status history of an account on GET and
+// changes its status on PUT.
+func (h *Handler) handleAccountStatus(w http.ResponseWriter, r *http.Request) error {
+	number := getNumber(r)
+	if number == -1 {
+		return errInvalidNumber
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		resp, err := h.service.GetAccountStatusHistory(r.Context(), param.GetAccountStatusHistoryRequest{Number: number})
+		if err != nil {
+			return err
+		}
+		return WriteJSON(w, http.StatusOK, resp)
+	case http.MethodPut:
+		var req param.ChangeAccountStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+		}
+		defer r.Body.Close()
+		req.Number = number
+		req.Actor = actorOf(r.Context())
+
+		if err := h.service.ChangeAccountStatus(r.Context(), req); err != nil {
+			return err
+		}
+		return WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("the account is now %s.", req.Status)})
+	}
+
+	return fmt.Errorf("%w: %s", errs.ErrMethodNotAllowed, r.Method)
+}
+
+func (h *Handler) handleSetAccountRole(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPut {
+		return errs.ErrMethodNotAllowed
+	}
+
+	number := getNumber(r)
+	if number == -1 {
+		return errInvalidNumber
+	}
+
+	var req param.SetAccountRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+	}
+	defer r.Body.Close()
+	req.Number = number
+
+	if err := h.service.SetAccountRole(r.Context(), req); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "the role of the account has been changed."})
 }
 
 func (h *Handler) handleTransfer(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != http.MethodPost {
-		return fmt.Errorf("invalid method")
+		return errs.ErrMethodNotAllowed
 	}
 	var req param.TransferAmountRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return fmt.Errorf("cannot bind the request body: %w", err)
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
 	}
 
 	defer r.Body.Close()
 
+	// money only leaves an account on behalf of its holder
+	if claims := claimsFrom(r.Context()); claims.Number != req.FromAccount {
+		return errs.ErrPermissionDenied
+	}
+
+	return h.transfer(w, r, req)
+}
+
+// handleMeTransfer always sends from the account of the access token,
+// whatever from_account the body names.
+func (h *Handler) handleMeTransfer(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+	var req param.TransferAmountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+	}
+	defer r.Body.Close()
+	req.FromAccount = getNumber(r)
+
+	return h.transfer(w, r, req)
+}
+
+func (h *Handler) transfer(w http.ResponseWriter, r *http.Request, req param.TransferAmountRequest) error {
+	req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	req.TOTPCode = r.Header.Get(totpHeader)
+
 	response, err := h.service.TransferAmount(r.Context(), req)
 	if err != nil {
-		return WriteJSON(w, http.StatusBadRequest, response)
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handleDeposit(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+
+	number := getNumber(r)
+	if number == -1 {
+		return errInvalidNumber
+	}
+
+	var req param.DepositRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+	}
+	defer r.Body.Close()
+	req.Number = number
+
+	response, err := h.service.Deposit(r.Context(), req)
+	if err != nil {
+		return err
 	}
 
 	return WriteJSON(w, http.StatusOK, response)
 }
 
+func (h *Handler) handleWithdraw(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+
+	number := getNumber(r)
+	if number == -1 {
+		return errInvalidNumber
+	}
+
+	var req param.WithdrawRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+	}
+	defer r.Body.Close()
+	req.Number = number
+
+	response, err := h.service.Withdraw(r.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handleGetTransactions(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return errs.ErrMethodNotAllowed
+	}
+
+	number := getNumber(r)
+	if number == -1 {
+		return errInvalidNumber
+	}
+
+	req, err := parseTransactionsQuery(r)
+	if err != nil {
+		return err
+	}
+	req.Number = number
+
+	response, err := h.service.GetTransactions(r.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, response)
+}
+
+func parseTransactionsQuery(r *http.Request) (param.GetTransactionsRequest, error) {
+	q := r.URL.Query()
+	req := param.GetTransactionsRequest{
+		Cursor:    q.Get("cursor"),
+		Direction: entity.Direction(q.Get("direction")),
+	}
+
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			return req, fmt.Errorf("%w: invalid limit", errs.ErrInvalidRequest)
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		if req.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return req, fmt.Errorf("%w: invalid from", errs.ErrInvalidRequest)
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if req.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return req, fmt.Errorf("%w: invalid to", errs.ErrInvalidRequest)
+		}
+	}
+	if v := q.Get("min_amount"); v != "" {
+		if req.MinAmount, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return req, fmt.Errorf("%w: invalid min_amount", errs.ErrInvalidRequest)
+		}
+	}
+	if v := q.Get("max_amount"); v != "" {
+		if req.MaxAmount, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return req, fmt.Errorf("%w: invalid max_amount", errs.ErrInvalidRequest)
+		}
+	}
+
+	return req, nil
+}
+
 func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != http.MethodPost {
-		return WriteJSON(w, http.StatusBadRequest)
+		return errs.ErrMethodNotAllowed
 	}
 
 	var req param.LoginRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		return WriteJSON(w, http.StatusBadRequest)
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
 	}
 
 	// checking correctness of password
 	passCheck, err := h.service.CheckPass(r.Context(), req)
-	// TODO: check the response
 	if err != nil {
-		return WriteJSON(w, http.StatusBadRequest)
+		return err
+	}
+	if !passCheck.Truly {
+		return errs.ErrInvalidCredentials
 	}
-	if passCheck.Truly {
 
-		resp, err := h.auth.CreateAccessToken(req)
+	mfa, err := h.service.MFAEnabled(r.Context(), req.Number)
+	if err != nil {
+		return err
+	}
+	if mfa {
+		token, err := h.auth.CreateMFAToken(req.Number)
 		if err != nil {
-			return WriteJSON(w, http.StatusInternalServerError, []byte("authentication failed."))
+			return err
 		}
-		w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", resp.TokenString))
+		return WriteJSON(w, http.StatusOK, param.LoginResponse{MFAToken: token, Status: param.LoginMFARequired})
+	}
+
+	return h.login(w, r, req.Number)
+}
+
+// handleLoginMFA completes a login of an account with two-factor
+// authentication.
+func (h *Handler) handleLoginMFA(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+
+	var req param.LoginMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+	}
+
+	number, err := h.auth.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		return err
+	}
+	if err := h.service.VerifyLoginMFA(r.Context(), param.MFACodeRequest{Number: number, Code: req.Code}); err != nil {
+		return err
+	}
+
+	return h.login(w, r, number)
+}
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request, number int64) error {
+	resp, err := h.auth.Login(r.Context(), param.LoginRequest{Number: number})
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", resp.TokenString))
+
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleRefreshToken(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
 
-		return WriteJSON(w, http.StatusOK, resp.Status)
+	var req param.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
 	}
 
-	// TODO: check the response
-	return WriteJSON(w, http.StatusBadRequest)
+	resp, err := h.auth.Refresh(r.Context(), req)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", resp.TokenString))
 
+	return WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleLogout(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+
+	var req param.LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+	}
+
+	if err := h.auth.Logout(r.Context(), req); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) handleRequestPasswordReset(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+
+	var req param.RequestPasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+	}
+
+	if err := h.service.RequestPasswordReset(r.Context(), req); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusAccepted, map[string]string{"message": "if the account exists, a reset token has been sent to its holder."})
+}
+
+func (h *Handler) handleResetPassword(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+
+	var req param.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+	}
+
+	if err := h.service.ResetPassword(r.Context(), req); err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "the password has been reset; please log in again."})
+}
+
+func (h *Handler) handleJWKS(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return errs.ErrMethodNotAllowed
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	return WriteJSON(w, http.StatusOK, h.auth.JWKS())
 }
 
 func getNumber(r *http.Request) int64 {
@@ -201,3 +782,13 @@ func getNumber(r *http.Request) int64 {
 	}
 	return int64(n)
 }
+
+// decodeOptional decodes the JSON body of r into v; an empty body leaves v
+// untouched.
+func decodeOptional(r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+	}
+	return nil
+}
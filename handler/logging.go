@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/logging"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+// requestLogger takes the request ID from X-Request-ID, or generates one,
+// stores it in the request context and echoes it in the response. Each
+// request is logged once it is served.
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = entity.NewID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(logging.WithRequestID(r.Context(), id))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		slog.InfoContext(r.Context(), "request served",
+			"method", r.Method,
+			"route", routeTemplate(r),
+			"status", rec.status,
+			"duration", time.Since(start),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+// validRequestID accepts short printable IDs so a client cannot inject
+// arbitrary text into the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIDIsEchoedOrGenerated(t *testing.T) {
+	h := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	h.server.Handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get(requestIDHeader); got != "abc-123" {
+		t.Errorf("expected the client request ID to be echoed, but got %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set(requestIDHeader, "bad id\nwith newline")
+	rec = httptest.NewRecorder()
+	h.server.Handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get(requestIDHeader); got == "" || got == "bad id\nwith newline" {
+		t.Errorf("expected a generated request ID, but got %q", got)
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mohamadafzal06/depository/config"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/ratelimit"
+)
+
+// maxPeekedBody bounds how much of a request body is read to find the target
+// account of a rate limited request.
+const maxPeekedBody = 1 << 20
+
+// rateLimited limits the requests of route per client IP and per target
+// account, as configured for route. The most restrictive bucket is reported in
+// the RateLimit-* headers.
+func (h *Handler) rateLimited(route string, next http.HandlerFunc) http.HandlerFunc {
+	limits, ok := h.rateLimit.Routes[route]
+	if !h.rateLimit.Enabled || !ok {
+		return next
+	}
+	ipLimit := toLimit(limits.IP)
+	accountLimit := toLimit(limits.Account)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		results := []ratelimit.Result{h.allow(r, route+":ip:"+h.clientIP(r), ipLimit)}
+		if number, ok := h.targetAccount(r); ok {
+			results = append(results, h.allow(r, route+":account:"+strconv.FormatInt(number, 10), accountLimit))
+		}
+
+		res := mostRestrictive(results)
+		if res.Limit > 0 {
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset.Seconds())))
+		}
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter.Seconds())))
+			writeError(w, r, errs.ErrRateLimited)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// allow fails open: an unavailable limiter must not take the API down.
+func (h *Handler) allow(r *http.Request, key string, limit ratelimit.Limit) ratelimit.Result {
+	res, err := h.limiter.Allow(r.Context(), key, limit)
+	if err != nil {
+		slog.WarnContext(r.Context(), "rate limiter unavailable", "error", err)
+		return ratelimit.Result{Allowed: true}
+	}
+	return res
+}
+
+func mostRestrictive(results []ratelimit.Result) ratelimit.Result {
+	res := ratelimit.Result{Allowed: true}
+	for _, r := range results {
+		switch {
+		case !r.Allowed && (res.Allowed || r.RetryAfter > res.RetryAfter):
+			res = r
+		case res.Allowed && r.Limit > 0 && (res.Limit == 0 || r.Remaining < res.Remaining):
+			res = r
+		}
+	}
+	return res
+}
+
+// clientIP returns the address the request came from. Behind a trusted proxy
+// that is the last X-Forwarded-For entry, the one the proxy appended; the
+// entries before it are whatever the client sent.
+func (h *Handler) clientIP(r *http.Request) string {
+	if h.rateLimit.TrustProxy {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			last := fwd[len(fwd)-1]
+			if i := strings.LastIndex(last, ","); i >= 0 {
+				last = last[i+1:]
+			}
+			if ip := strings.TrimSpace(last); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// targetAccount returns the account a request acts on: the {number} of the
+// path, or the number, from_account or account of the mfa_token of a JSON
+// body. The body is restored for the handler.
+func (h *Handler) targetAccount(r *http.Request) (int64, bool) {
+	if v, ok := mux.Vars(r)["number"]; ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	if r.Body == nil {
+		return 0, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPeekedBody))
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return 0, false
+	}
+
+	var target struct {
+		Number      int64  `json:"number"`
+		FromAccount int64  `json:"from_account"`
+		MFAToken    string `json:"mfa_token"`
+	}
+	if err := json.Unmarshal(body, &target); err != nil {
+		return 0, false
+	}
+	if target.Number != 0 {
+		return target.Number, true
+	}
+	if target.MFAToken != "" {
+		number, err := h.auth.ParseMFAToken(target.MFAToken)
+		return number, err == nil
+	}
+	return target.FromAccount, target.FromAccount != 0
+}
+
+func toLimit(l config.RateLimit) ratelimit.Limit {
+	return ratelimit.Limit{Requests: l.Requests, Per: l.Per}
+}
+
+func ceilSeconds(s float64) int {
+	return int(math.Ceil(s))
+}
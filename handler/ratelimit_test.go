@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mohamadafzal06/depository/param"
+)
+
+func TestLoginIsRateLimitedPerAccount(t *testing.T) {
+	h := newTestHandler(t)
+
+	login := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"number": 12345678, "password": "guess"}`))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		h.server.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the default login limit is 5 attempts per account and minute
+	for i := 0; i < 5; i++ {
+		if rec := login(fmt.Sprintf("10.0.0.%d:1234", i+1)); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected attempt %d to reach the password check, but got %d", i+1, rec.Code)
+		}
+	}
+
+	rec := login("10.0.0.9:1234")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, but got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("expected Retry-After and RateLimit-* headers, but got %v", rec.Header())
+	}
+}
+
+func TestLoginIPLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	h := newTestHandler(t)
+	h.rateLimit.TrustProxy = true
+
+	// the client makes up the first entry, the proxy appends the real address
+	login := func(i int) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"number": %d, "password": "guess"}`, 10000000+i)
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d, 203.0.113.7", i))
+		rec := httptest.NewRecorder()
+		h.server.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the default login limit is 20 attempts per IP and minute
+	for i := 0; i < 20; i++ {
+		if rec := login(i); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected attempt %d to reach the password check, but got %d", i+1, rec.Code)
+		}
+	}
+	if rec := login(20); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, but got %d", rec.Code)
+	}
+}
+
+func TestLoginMFAIsRateLimitedPerAccount(t *testing.T) {
+	h := newTestHandler(t)
+
+	acc, err := h.service.CreateAccount(context.Background(), param.CreateAccountRequest{FistName: "John", LastName: "Doe", Password: "mypassword"})
+	if err != nil {
+		t.Fatalf("unexpected error while creating account: %s", err.Error())
+	}
+	token, err := h.auth.CreateMFAToken(acc.Number)
+	if err != nil {
+		t.Fatalf("unexpected error while creating MFA token: %s", err.Error())
+	}
+
+	verify := func(remoteAddr string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"mfa_token": %q, "code": "000000"}`, token)
+		req := httptest.NewRequest(http.MethodPost, "/login/mfa", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		h.server.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the default login limit is 5 attempts per account and minute
+	for i := 0; i < 5; i++ {
+		if rec := verify(fmt.Sprintf("10.0.0.%d:1234", i+1)); rec.Code == http.StatusTooManyRequests {
+			t.Fatalf("expected attempt %d to reach the code check, but got %d", i+1, rec.Code)
+		}
+	}
+	if rec := verify("10.0.0.9:1234"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, but got %d", rec.Code)
+	}
+}
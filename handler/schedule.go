@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+func (h *Handler) handleSchedules(w http.ResponseWriter, r *http.Request) error {
+	number := getNumber(r)
+	if number == -1 {
+		return errInvalidNumber
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		response, err := h.service.ListScheduledTransfers(r.Context(), param.ListScheduledTransfersRequest{Number: number})
+		if err != nil {
+			return err
+		}
+		return WriteJSON(w, http.StatusOK, response)
+	case http.MethodPost:
+		var req param.ScheduleTransferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+		}
+		defer r.Body.Close()
+		req.Number = number
+		req.TOTPCode = r.Header.Get(totpHeader)
+
+		response, err := h.service.CreateScheduledTransfer(r.Context(), req)
+		if err != nil {
+			return err
+		}
+		return WriteJSON(w, http.StatusOK, response)
+	}
+
+	return fmt.Errorf("%w: %s", errs.ErrMethodNotAllowed, r.Method)
+}
+
+func (h *Handler) handleSchedule(w http.ResponseWriter, r *http.Request) error {
+	req, err := getScheduleRequest(r)
+	if err != nil {
+		return err
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		response, err := h.service.GetScheduledTransfer(r.Context(), req)
+		if err != nil {
+			return err
+		}
+		return WriteJSON(w, http.StatusOK, response)
+	case http.MethodPut:
+		var updateReq param.ScheduleTransferRequest
+		if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
+			return fmt.Errorf("%w: cannot bind request body: %v", errs.ErrInvalidRequest, err)
+		}
+		defer r.Body.Close()
+		updateReq.Number = req.Number
+		updateReq.ID = req.ID
+		updateReq.TOTPCode = r.Header.Get(totpHeader)
+
+		response, err := h.service.UpdateScheduledTransfer(r.Context(), updateReq)
+		if err != nil {
+			return err
+		}
+		return WriteJSON(w, http.StatusOK, response)
+	case http.MethodDelete:
+		if err := h.service.DeleteScheduledTransfer(r.Context(), req); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", errs.ErrMethodNotAllowed, r.Method)
+}
+
+func (h *Handler) handlePauseSchedule(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+
+	req, err := getScheduleRequest(r)
+	if err != nil {
+		return err
+	}
+
+	response, err := h.service.PauseScheduledTransfer(r.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handleResumeSchedule(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return errs.ErrMethodNotAllowed
+	}
+
+	req, err := getScheduleRequest(r)
+	if err != nil {
+		return err
+	}
+
+	response, err := h.service.ResumeScheduledTransfer(r.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handleScheduleExecutions(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return errs.ErrMethodNotAllowed
+	}
+
+	req, err := getScheduleRequest(r)
+	if err != nil {
+		return err
+	}
+
+	response, err := h.service.ListScheduledTransferExecutions(r.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return WriteJSON(w, http.StatusOK, response)
+}
+
+func getScheduleRequest(r *http.Request) (param.GetScheduledTransferRequest, error) {
+	number := getNumber(r)
+	if number == -1 {
+		return param.GetScheduledTransferRequest{}, errInvalidNumber
+	}
+
+	return param.GetScheduledTransferRequest{Number: number, ID: mux.Vars(r)["id"]}, nil
+}
@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestLoggerRedactsAndAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.InfoContext(ctx, "login", "number", int64(12345678), "password", "hunter2", slog.Group("tokens", "refresh_token", "abc"))
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unexpected error while decoding record: %s", err.Error())
+	}
+
+	if record["request_id"] != "req-1" {
+		t.Errorf("expected request_id req-1, but got %v", record["request_id"])
+	}
+	if record["number"] != "****5678" {
+		t.Errorf("expected masked number, but got %v", record["number"])
+	}
+	if record["password"] != redacted {
+		t.Errorf("expected redacted password, but got %v", record["password"])
+	}
+	if tokens, _ := record["tokens"].(map[string]interface{}); tokens["refresh_token"] != redacted {
+		t.Errorf("expected redacted refresh token, but got %v", record["tokens"])
+	}
+}
@@ -1,26 +1,193 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/mohamadafzal06/depository/config"
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/exchange"
 	"github.com/mohamadafzal06/depository/handler"
+	"github.com/mohamadafzal06/depository/logging"
+	"github.com/mohamadafzal06/depository/metrics"
+	"github.com/mohamadafzal06/depository/notify"
+	"github.com/mohamadafzal06/depository/ratelimit"
+	"github.com/mohamadafzal06/depository/repository"
+	"github.com/mohamadafzal06/depository/repository/memory"
 	"github.com/mohamadafzal06/depository/repository/postgres"
 	"github.com/mohamadafzal06/depository/service"
+	"github.com/mohamadafzal06/depository/tracing"
 )
 
 func main() {
-	repo, err := postgres.NewPostgres()
+	args := os.Args[1:]
+	migrate := len(args) > 0 && args[0] == "migrate"
+	validate := config.Config.Validate
+	if migrate {
+		args = args[1:]
+		validate = config.Config.ValidateDatabase
+	}
+
+	cfg, args, err := config.Load(args, validate)
+	if err != nil {
+		fatal(err)
+	}
+
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	slog.SetDefault(logging.New(os.Stdout, level))
+
+	if migrate {
+		if err := runMigrate(cfg, args); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal(err)
+	}
+
+	m := metrics.New()
+
+	repo, err := newRepository(cfg, m)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+
+	var rates exchange.RateProvider
+	if cfg.Exchange.RatesFile != "" {
+		rates, err = exchange.LoadStaticFile(cfg.Exchange.RatesFile)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	depository := service.NewDepository(repo, rates)
+	depository.SetLockoutPolicy(service.LockoutPolicy{
+		MaxFailures: cfg.Auth.Lockout.MaxFailures,
+		Window:      cfg.Auth.Lockout.Window,
+		BaseLockout: cfg.Auth.Lockout.BaseDuration,
+		MaxLockout:  cfg.Auth.Lockout.MaxDuration,
+	})
+	limits := make(map[string]entity.TransferLimits, len(cfg.Limits))
+	for currency, l := range cfg.Limits {
+		limits[currency] = entity.TransferLimits{
+			MaxSingleTransfer:   l.MaxSingleTransfer,
+			DailyOutgoing:       l.DailyOutgoing,
+			MonthlyOutgoing:     l.MonthlyOutgoing,
+			MaxTransfersPerHour: l.MaxTransfersPerHour,
+		}
+	}
+	depository.SetTransferLimits(limits)
+	depository.SetPasswordPolicy(service.PasswordPolicy{
+		MinLength:     cfg.Auth.Password.MinLength,
+		RequireUpper:  cfg.Auth.Password.RequireUpper,
+		RequireLower:  cfg.Auth.Password.RequireLower,
+		RequireDigit:  cfg.Auth.Password.RequireDigit,
+		RequireSymbol: cfg.Auth.Password.RequireSymbol,
+	})
+	var notifier notify.Notifier
+	switch cfg.Notifier.Kind {
+	case "log":
+		notifier = notify.NewLog(slog.Default())
+	case "file":
+		notifier = notify.NewFile(cfg.Notifier.File)
 	}
+	depository.SetPasswordReset(notifier, cfg.Auth.Password.ResetTokenTTL)
+	depository.SetMFAPolicy(service.MFAPolicy{
+		Issuer:            cfg.Auth.MFA.Issuer,
+		TransferThreshold: cfg.Auth.MFA.TransferThreshold,
+	})
 
-	if err := repo.Init(); err != nil {
-		log.Fatal(err)
+	keys := make([]service.KeyConfig, 0, len(cfg.Auth.Keys))
+	for _, k := range cfg.Auth.Keys {
+		keys = append(keys, service.KeyConfig{
+			ID:             k.ID,
+			Algorithm:      k.Algorithm,
+			PrivateKeyFile: k.PrivateKeyFile,
+			PublicKeyFile:  k.PublicKeyFile,
+			SecretFile:     k.SecretFile,
+		})
+	}
+
+	auth, err := service.NewAuth(service.AuthConfig{
+		SignKey:               cfg.Auth.SignKey,
+		Keys:                  keys,
+		ActiveKeyID:           cfg.Auth.ActiveKey,
+		AccessExpirationTime:  cfg.Auth.AccessExpiration,
+		RefreshExpirationTime: cfg.Auth.RefreshExpiration,
+		AccessSubject:         "at",
+		RefreshSubject:        "rt",
+		MFASubject:            "mfa",
+		MFAExpirationTime:     cfg.Auth.MFA.TokenExpiration,
+		AdminKey:              cfg.Auth.AdminKey,
+	}, repo)
+	if err != nil {
+		fatal(err)
 	}
 
-	service := service.NewDepository(repo)
+	scheduler := service.NewScheduler(depository, cfg.Scheduler.Interval)
+	scheduler.Start()
 
-	handler := handler.New(":8999", service)
+	h := handler.New(cfg.HTTP, depository, auth, m, ratelimit.NewMemory())
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- h.Start()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			slog.Error("server failed", "error", err)
+		}
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	}
+
+	// drain in-flight requests and the running scheduler batch before the
+	// repository goes away
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := h.Shutdown(shutdownCtx); err != nil {
+		slog.Error("cannot drain requests", "error", err)
+	}
+	scheduler.Stop()
+
+	if err := repo.Close(); err != nil {
+		slog.Error("cannot close repository", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("cannot flush spans", "error", err)
+	}
+}
+
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+func newRepository(cfg config.Config, m *metrics.Metrics) (repository.Repository, error) {
+	if cfg.Repository == "memory" {
+		return metrics.NewRepository(memory.New(), m), nil
+	}
+
+	pg, err := postgres.NewPostgres(cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pg.Init(); err != nil {
+		return nil, err
+	}
+	m.RegisterDBStats(pg)
 
-	handler.Run()
+	return metrics.NewRepository(pg, m), nil
 }
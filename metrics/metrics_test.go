@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/repository/memory"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareUsesRouteTemplate(t *testing.T) {
+	m := New()
+	router := mux.NewRouter()
+	router.Use(m.Middleware)
+	router.HandleFunc("/account/{number}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, path := range []string{"/account/12345678", "/account/87654321"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("/account/{number}", http.MethodGet, "404")); got != 2 {
+		t.Errorf("expected 2 requests on the route, but got %v", got)
+	}
+}
+
+func TestRepositoryCountsTransferOutcomes(t *testing.T) {
+	m := New()
+	repo := NewRepository(memory.New(), m)
+	ctx := context.Background()
+
+	acc, _ := entity.NewAccount("John", "Doe", "mypassword", 0)
+	if _, err := repo.CreateAccount(ctx, acc); err != nil {
+		t.Fatalf("unexpected error while creating account: %s", err.Error())
+	}
+
+	if err := repo.Deposit(ctx, entity.NewDeposit(acc.Number, 100, acc.Currency, "")); err != nil {
+		t.Fatalf("unexpected error while depositing: %s", err.Error())
+	}
+	if err := repo.Withdraw(ctx, entity.NewWithdrawal(acc.Number, 500, acc.Currency, "")); err == nil {
+		t.Fatal("expected withdrawing more than the balance to fail")
+	}
+
+	if got := testutil.ToFloat64(m.transferAmount.WithLabelValues("deposit", outcomeSuccess, acc.Currency)); got != 100 {
+		t.Errorf("expected 100 deposited, but got %v", got)
+	}
+	if got := testutil.ToFloat64(m.transfers.WithLabelValues("withdrawal", string(errs.CodeInsufficientFunds))); got != 1 {
+		t.Errorf("expected 1 failed withdrawal, but got %v", got)
+	}
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/repository"
+)
+
+const outcomeSuccess = "success"
+
+// Repository decorates a repository.Repository with transfer and login
+// metrics. Every method it does not override is passed through.
+type Repository struct {
+	repository.Repository
+	metrics *Metrics
+}
+
+var _ repository.Repository = (*Repository)(nil)
+var _ repository.SchemaChecker = (*Repository)(nil)
+
+func NewRepository(r repository.Repository, m *Metrics) *Repository {
+	return &Repository{Repository: r, metrics: m}
+}
+
+func (r *Repository) TransferAmount(ctx context.Context, tr *entity.Transfer) error {
+	err := r.Repository.TransferAmount(ctx, tr)
+	r.metrics.observeTransfer(tr, err)
+	return err
+}
+
+func (r *Repository) CompleteIdempotentTransfer(ctx context.Context, tr *entity.Transfer, rec *entity.IdempotencyRecord) error {
+	err := r.Repository.CompleteIdempotentTransfer(ctx, tr, rec)
+	r.metrics.observeTransfer(tr, err)
+	return err
+}
+
+func (r *Repository) Deposit(ctx context.Context, tr *entity.Transfer) error {
+	err := r.Repository.Deposit(ctx, tr)
+	r.metrics.observeTransfer(tr, err)
+	return err
+}
+
+func (r *Repository) Withdraw(ctx context.Context, tr *entity.Transfer) error {
+	err := r.Repository.Withdraw(ctx, tr)
+	r.metrics.observeTransfer(tr, err)
+	return err
+}
+
+func (r *Repository) AccountAuthenticity(ctx context.Context, number int64, encPass string) error {
+	err := r.Repository.AccountAuthenticity(ctx, number, encPass)
+	r.metrics.logins.WithLabelValues(outcome(err)).Inc()
+	return err
+}
+
+// SchemaCurrent forwards to the decorated repository so readiness still sees
+// pending migrations.
+func (r *Repository) SchemaCurrent(ctx context.Context) (bool, error) {
+	if checker, ok := r.Repository.(repository.SchemaChecker); ok {
+		return checker.SchemaCurrent(ctx)
+	}
+	return true, nil
+}
+
+// observeTransfer counts tr under the amount and currency of its first entry,
+// which the entity constructors always make the debit.
+func (m *Metrics) observeTransfer(tr *entity.Transfer, err error) {
+	kind := string(tr.Kind)
+	result := outcome(err)
+	m.transfers.WithLabelValues(kind, result).Inc()
+
+	if len(tr.Entries) > 0 {
+		debit := tr.Entries[0]
+		m.transferAmount.WithLabelValues(kind, result, debit.Currency).Add(float64(-debit.Amount))
+	}
+}
+
+func outcome(err error) string {
+	if err == nil {
+		return outcomeSuccess
+	}
+	return string(errs.CodeOf(err))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mohamadafzal06/depository/config"
+	"github.com/mohamadafzal06/depository/repository/postgres"
+)
+
+const migrateUsage = "usage: depository migrate up|down [steps]|status"
+
+// runMigrate implements the "depository migrate" subcommand.
+func runMigrate(cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	pg, err := postgres.NewPostgres(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer pg.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return pg.MigrateUp(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		return pg.MigrateDown(ctx, steps)
+	case "status":
+		status, err := pg.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+
+	return errors.New(migrateUsage)
+}
@@ -0,0 +1,74 @@
+// Package notify delivers messages to account holders, such as password reset
+// tokens. The implementations here are only meant for local development, since
+// whoever reads their output can reset any password; production deployments
+// plug in their own mail or SMS Notifier.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message is addressed to the holder of account To.
+type Message struct {
+	To      int64     `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Log records that a message was sent. The body may hold a reset token, so
+// it is never logged; use File to read messages during development.
+type Log struct {
+	logger *slog.Logger
+}
+
+func NewLog(logger *slog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+func (l *Log) Notify(ctx context.Context, msg Message) error {
+	l.logger.InfoContext(ctx, "notification", "account", msg.To, "subject", msg.Subject)
+	return nil
+}
+
+// File appends messages, reset tokens included, as JSON lines to a file.
+type File struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Notify(ctx context.Context, msg Message) error {
+	if msg.SentAt.IsZero() {
+		msg.SentAt = time.Now().UTC()
+	}
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot open notification file: %w", err)
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
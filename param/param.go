@@ -1,6 +1,10 @@
 package param
 
-import "time"
+import (
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+)
 
 type TransferStatus string
 
@@ -14,6 +18,8 @@ type LoginStatus string
 const (
 	LoginSuccessful   LoginStatus = "Login Successful"
 	LoginUnsuccessful LoginStatus = "Login Unsuccessful"
+	// LoginMFARequired asks for a TOTP or recovery code with the MFA token.
+	LoginMFARequired LoginStatus = "MFA Required"
 )
 
 type CreateAccountRequest struct {
@@ -21,35 +27,226 @@ type CreateAccountRequest struct {
 	LastName string `json:"last_name"`
 	Password string `json:"password"`
 	Balance  int64  `json:"balance"`
+	Currency string `json:"currency"`
 }
 type CreateAccountResponse struct {
 	FistName string `json:"fist_name"`
 	LastName string `json:"last_name"`
 	Number   int64  `json:"number"`
+	Currency string `json:"currency"`
 }
 
 type GetAccountByNumberRequest struct {
 	Number int64 `json:"number"`
 }
 type GetAccountByNumberResponse struct {
-	FistName  string    `json:"fist_name"`
-	LastName  string    `json:"last_name"`
-	Number    int64     `json:"number"`
-	Balance   int64     `json:"balance"`
-	CreatedAt time.Time `json:"created_at"`
+	FistName  string               `json:"fist_name"`
+	LastName  string               `json:"last_name"`
+	Number    int64                `json:"number"`
+	Balance   int64                `json:"balance"`
+	Currency  string               `json:"currency"`
+	Role      entity.Role          `json:"role"`
+	Status    entity.AccountStatus `json:"status"`
+	CreatedAt time.Time            `json:"created_at"`
 }
 
-type DeleteAccountRequest struct {
-	Number int64 `json:"number"`
+// CloseAccountRequest closes an account for good; Actor is filled in from
+// the access token.
+type CloseAccountRequest struct {
+	Number int64  `json:"number"`
+	Reason string `json:"reason"`
+	Actor  string `json:"-"`
 }
 
 type TransferAmountRequest struct {
-	FromAccount int64 `json:"from_account"`
-	ToAccount   int64 `json:"to_account"`
-	Amount      int64 `json:"amount"`
+	FromAccount    int64  `json:"from_account"`
+	ToAccount      int64  `json:"to_account"`
+	Amount         int64  `json:"amount"`
+	Currency       string `json:"currency"`
+	Reference      string `json:"reference"`
+	IdempotencyKey string `json:"-"`
+	// TOTPCode comes from the X-TOTP-Code header, so that retrying with a
+	// new code does not change the idempotent request.
+	TOTPCode string `json:"-"`
+	// Scheduled is set for runs of a scheduled transfer.
+	Scheduled bool `json:"-"`
 }
+
+// TransferAmountResponse reports the debited amount in the currency of the
+// sender and the credited amount in the currency of the receiver, together
+// with the applied exchange rate when they differ.
 type TransferAmountResponse struct {
-	Status TransferStatus `json:"status"`
+	Status           TransferStatus `json:"status"`
+	TransferID       string         `json:"transfer_id,omitempty"`
+	Amount           int64          `json:"amount,omitempty"`
+	Currency         string         `json:"currency,omitempty"`
+	CreditedAmount   int64          `json:"credited_amount,omitempty"`
+	CreditedCurrency string         `json:"credited_currency,omitempty"`
+	Rate             string         `json:"rate,omitempty"`
+}
+
+type DepositRequest struct {
+	Number    int64  `json:"number"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+	Reference string `json:"reference"`
+}
+type DepositResponse struct {
+	Status     TransferStatus `json:"status"`
+	TransferID string         `json:"transfer_id,omitempty"`
+	Amount     int64          `json:"amount,omitempty"`
+	Currency   string         `json:"currency,omitempty"`
+	Balance    int64          `json:"balance,omitempty"`
+}
+
+type WithdrawRequest struct {
+	Number    int64  `json:"number"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+	Reference string `json:"reference"`
+}
+type WithdrawResponse struct {
+	Status     TransferStatus `json:"status"`
+	TransferID string         `json:"transfer_id,omitempty"`
+	Amount     int64          `json:"amount,omitempty"`
+	Currency   string         `json:"currency,omitempty"`
+	Balance    int64          `json:"balance,omitempty"`
+}
+
+type GetLedgerEntriesRequest struct {
+	Number int64 `json:"number"`
+}
+type GetLedgerEntriesResponse struct {
+	Entries []entity.LedgerEntry `json:"entries"`
+}
+
+type GetTransactionsRequest struct {
+	Number    int64            `json:"number"`
+	Cursor    string           `json:"cursor"`
+	Limit     int              `json:"limit"`
+	From      time.Time        `json:"from"`
+	To        time.Time        `json:"to"`
+	Direction entity.Direction `json:"direction"`
+	MinAmount int64            `json:"min_amount"`
+	MaxAmount int64            `json:"max_amount"`
+}
+type GetTransactionsResponse struct {
+	Transactions []entity.Transaction `json:"transactions"`
+	NextCursor   string               `json:"next_cursor,omitempty"`
+}
+
+// ScheduleTransferRequest creates a scheduled transfer out of Number, or
+// replaces the terms of the one with ID.
+type ScheduleTransferRequest struct {
+	Number         int64                    `json:"number"`
+	ID             string                   `json:"id"`
+	ToAccount      int64                    `json:"to_account"`
+	Amount         int64                    `json:"amount"`
+	Currency       string                   `json:"currency"`
+	Reference      string                   `json:"reference"`
+	Frequency      entity.ScheduleFrequency `json:"frequency"`
+	CronExpression string                   `json:"cron_expression"`
+	StartAt        time.Time                `json:"start_at"`
+	EndAt          time.Time                `json:"end_at"`
+	MaxAttempts    int                      `json:"max_attempts"`
+	RetryBackoff   string                   `json:"retry_backoff"`
+	TOTPCode       string                   `json:"-"`
+}
+type ScheduledTransferResponse struct {
+	Schedule entity.ScheduledTransfer `json:"schedule"`
+}
+
+type GetScheduledTransferRequest struct {
+	Number int64  `json:"number"`
+	ID     string `json:"id"`
+}
+
+type ListScheduledTransfersRequest struct {
+	Number int64 `json:"number"`
+}
+type ListScheduledTransfersResponse struct {
+	Schedules []entity.ScheduledTransfer `json:"schedules"`
+}
+
+type ListScheduledTransferExecutionsResponse struct {
+	Executions []entity.ScheduledTransferExecution `json:"executions"`
+}
+
+type ChangePasswordRequest struct {
+	Number          int64  `json:"-"`
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type RequestPasswordResetRequest struct {
+	Number int64 `json:"number"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type SetAccountRoleRequest struct {
+	Number int64       `json:"number"`
+	Role   entity.Role `json:"role"`
+}
+
+type FreezeAccountRequest struct {
+	Number int64  `json:"number"`
+	Reason string `json:"reason"`
+	Actor  string `json:"-"`
+}
+
+type ChangeAccountStatusRequest struct {
+	Number int64                `json:"number"`
+	Status entity.AccountStatus `json:"status"`
+	Reason string               `json:"reason"`
+	Actor  string               `json:"-"`
+}
+
+type GetAccountStatusHistoryRequest struct {
+	Number int64 `json:"number"`
+}
+
+type GetAccountStatusHistoryResponse struct {
+	Number  int64                        `json:"number"`
+	Status  entity.AccountStatus         `json:"status"`
+	Changes []entity.AccountStatusChange `json:"changes"`
+}
+
+type GetTransferLimitsRequest struct {
+	Number int64 `json:"number"`
+}
+
+// UpdateTransferLimitsRequest replaces the limits of an account; a zero limit
+// falls back to the global one.
+type UpdateTransferLimitsRequest struct {
+	Number              int64 `json:"number"`
+	MaxSingleTransfer   int64 `json:"max_single_transfer"`
+	DailyOutgoing       int64 `json:"daily_outgoing"`
+	MonthlyOutgoing     int64 `json:"monthly_outgoing"`
+	MaxTransfersPerHour int   `json:"max_transfers_per_hour"`
+}
+
+// TransferLimitsResponse shows the limits set on an account, the global
+// limits of its currency and the ones transfers are actually checked
+// against, all in minor units of Currency.
+type TransferLimitsResponse struct {
+	Currency  string                `json:"currency"`
+	Account   entity.TransferLimits `json:"account"`
+	Global    entity.TransferLimits `json:"global"`
+	Effective entity.TransferLimits `json:"effective"`
+}
+
+type ReconcileAccountRequest struct {
+	Number int64 `json:"number"`
+}
+type ReconcileAccountResponse struct {
+	Number        int64 `json:"number"`
+	Balance       int64 `json:"balance"`
+	LedgerBalance int64 `json:"ledger_balance"`
+	Consistent    bool  `json:"consistent"`
 }
 
 type CreateTokenRequst struct {
@@ -62,10 +259,67 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	TokenString string
-	Status      LoginStatus
+	TokenString  string      `json:"access_token"`
+	RefreshToken string      `json:"refresh_token,omitempty"`
+	MFAToken     string      `json:"mfa_token,omitempty"`
+	Status       LoginStatus `json:"status"`
+}
+
+// LoginMFARequest completes a login with the token of the first step.
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type EnrollMFARequest struct {
+	Number int64 `json:"-"`
+}
+
+// EnrollMFAResponse is the only time the secret and the recovery codes are
+// shown.
+type EnrollMFAResponse struct {
+	Secret          string   `json:"secret"`
+	ProvisioningURI string   `json:"provisioning_uri"`
+	RecoveryCodes   []string `json:"recovery_codes"`
+}
+
+type MFACodeRequest struct {
+	Number int64  `json:"-"`
+	Code   string `json:"code"`
+}
+
+type DisableMFARequest struct {
+	Number   int64  `json:"-"`
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 type PassCheckRespone struct {
 	Truly bool
 }
+
+type UnlockAccountRequest struct {
+	Number int64 `json:"number"`
+}
+
+type ReadinessResponse struct {
+	Ready    bool              `json:"ready"`
+	Draining bool              `json:"draining,omitempty"`
+	Checks   map[string]string `json:"checks"`
+}
+
+type VersionResponse struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
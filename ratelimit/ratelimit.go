@@ -0,0 +1,124 @@
+// Package ratelimit implements token bucket rate limiting behind an interface
+// so the in-memory store can be replaced by a shared one.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Per on average, with bursts of up to Requests.
+// A zero Limit allows everything.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Per <= 0
+}
+
+// rate returns the number of tokens added per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed; zero when
+	// Allowed is true.
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	// Allow takes a token from the bucket of key, if there is one.
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// sweepEvery is how many calls of Allow pass between removing buckets that
+// have refilled completely and so carry no state.
+const sweepEvery = 1024
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// Memory is a Limiter keeping its buckets in the process.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+	now     func() time.Time
+}
+
+var _ Limiter = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if limit.Unlimited() {
+		return Result{Allowed: true}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.calls++
+	if m.calls%sweepEvery == 0 {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: float64(limit.Requests), updated: now, limit: limit}
+		m.buckets[key] = b
+	}
+	b.refill(now)
+
+	res := Result{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / limit.rate())
+	}
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = seconds((float64(limit.Requests) - b.tokens) / limit.rate())
+
+	return res, nil
+}
+
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Requests) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Requests), b.tokens+elapsed*b.limit.rate())
+		b.updated = now
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
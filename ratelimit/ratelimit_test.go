@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryRefillsOverTime(t *testing.T) {
+	now := time.Date(2023, time.June, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	limit := Limit{Requests: 2, Per: time.Minute}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if res, _ := m.Allow(ctx, "ip:1.2.3.4", limit); !res.Allowed {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+	}
+
+	res, _ := m.Allow(ctx, "ip:1.2.3.4", limit)
+	if res.Allowed {
+		t.Fatal("expected the third request to be limited")
+	}
+	if res.RetryAfter != 30*time.Second {
+		t.Errorf("expected to retry after 30s, but got %s", res.RetryAfter)
+	}
+
+	if res, _ := m.Allow(ctx, "ip:5.6.7.8", limit); !res.Allowed {
+		t.Error("expected another key to have its own bucket")
+	}
+
+	now = now.Add(30 * time.Second)
+	if res, _ := m.Allow(ctx, "ip:1.2.3.4", limit); !res.Allowed {
+		t.Error("expected a token to be refilled after 30s")
+	}
+}
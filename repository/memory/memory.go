@@ -0,0 +1,810 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/repository"
+)
+
+// Memory is a repository.Repository kept in process memory. Every method
+// holds the lock for its whole duration, which makes writes behave like the
+// serializable transactions of the postgres repository.
+type Memory struct {
+	mu sync.RWMutex
+
+	accounts    map[int64]*entity.Account
+	lastID      uint64
+	entries     []entity.LedgerEntry
+	transfers   map[string]*transfer
+	idempotency map[idempotencyKey]*entity.IdempotencyRecord
+	tokens      map[string]*entity.RefreshToken
+	schedules   map[string]*entity.ScheduledTransfer
+	executions  []entity.ScheduledTransferExecution
+	lockouts    map[int64]entity.LoginLockout
+	limits      map[int64]entity.TransferLimits
+	resets      map[string]*entity.PasswordResetToken
+	mfa         map[int64]entity.MFA
+	statuses    []entity.AccountStatusChange
+}
+
+var _ repository.Repository = (*Memory)(nil)
+
+// transfer indexes the ledger entries of a transfer.
+type transfer struct {
+	kind      entity.TransferKind
+	reference string
+	rate      string
+	entries   []int
+}
+
+// idempotencyKey scopes an idempotency key to its sending account.
+type idempotencyKey struct {
+	from int64
+	key  string
+}
+
+func keyOf(rec *entity.IdempotencyRecord) idempotencyKey {
+	return idempotencyKey{from: rec.FromAccount, key: rec.Key}
+}
+
+func New() *Memory {
+	return &Memory{
+		accounts:    make(map[int64]*entity.Account),
+		transfers:   make(map[string]*transfer),
+		idempotency: make(map[idempotencyKey]*entity.IdempotencyRecord),
+		tokens:      make(map[string]*entity.RefreshToken),
+		schedules:   make(map[string]*entity.ScheduledTransfer),
+		lockouts:    make(map[int64]entity.LoginLockout),
+		limits:      make(map[int64]entity.TransferLimits),
+		resets:      make(map[string]*entity.PasswordResetToken),
+		mfa:         make(map[int64]entity.MFA),
+	}
+}
+
+func (m *Memory) CreateAccount(ctx context.Context, acc *entity.Account) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.accounts[acc.Number]; ok {
+		return -1, fmt.Errorf("%w: %d", errs.ErrAccountExists, acc.Number)
+	}
+
+	m.lastID++
+	stored := *acc
+	stored.ID = m.lastID
+	stored.Balance = 0
+	m.accounts[stored.Number] = &stored
+
+	// the opening balance is recorded in the ledger like any other transfer
+	if acc.Balance != 0 {
+		if err := m.applyTransfer(entity.NewDeposit(acc.Number, acc.Balance, acc.Currency, "opening balance")); err != nil {
+			delete(m.accounts, stored.Number)
+			return -1, err
+		}
+	}
+
+	return stored.Number, nil
+}
+
+func (m *Memory) TransferAmount(ctx context.Context, tr *entity.Transfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.applyTransfer(tr)
+}
+
+func (m *Memory) Deposit(ctx context.Context, tr *entity.Transfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.applyTransfer(tr)
+}
+
+func (m *Memory) Withdraw(ctx context.Context, tr *entity.Transfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.applyTransfer(tr)
+}
+
+// applyTransfer validates every entry of tr before touching any balance, so a
+// failing transfer leaves no trace. The caller must hold the write lock.
+func (m *Memory) applyTransfer(tr *entity.Transfer) error {
+	if from := tr.Sender(); tr.Check != nil && from != entity.ExternalAccountNumber {
+		err := tr.Check(func(since time.Time) (entity.TransferStats, error) {
+			return m.outgoingTransferStats(from, since), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	balances := make(map[int64]int64)
+	for _, e := range tr.Entries {
+		if e.AccountNumber == entity.ExternalAccountNumber {
+			continue
+		}
+
+		acc, ok := m.accounts[e.AccountNumber]
+		if !ok {
+			return fmt.Errorf("%w: %d", errs.ErrAccountNotFound, e.AccountNumber)
+		}
+		if err := repository.CheckAccountStatus(e.AccountNumber, acc.Status, e.Amount < 0); err != nil {
+			return err
+		}
+		if e.Currency != acc.Currency {
+			return fmt.Errorf("%w: account %d holds %s", errs.ErrCurrencyMismatch, e.AccountNumber, acc.Currency)
+		}
+		if _, ok := balances[e.AccountNumber]; !ok {
+			balances[e.AccountNumber] = acc.Balance
+		}
+
+		balances[e.AccountNumber] += e.Amount
+		if balances[e.AccountNumber] < 0 {
+			return fmt.Errorf("%w: account %d", errs.ErrInsufficientFunds, e.AccountNumber)
+		}
+	}
+
+	record := &transfer{kind: tr.Kind, reference: tr.Reference, rate: tr.Rate}
+	for i := range tr.Entries {
+		e := &tr.Entries[i]
+		if e.AccountNumber != entity.ExternalAccountNumber {
+			acc := m.accounts[e.AccountNumber]
+			acc.Balance += e.Amount
+			e.Balance = acc.Balance
+			if acc.Status == entity.StatusPending && e.Amount > 0 {
+				change := entity.NewActivation(e.AccountNumber, tr.CreatedAt)
+				acc.Status = change.To
+				m.statuses = append(m.statuses, *change)
+			}
+		}
+
+		e.ID = int64(len(m.entries) + 1)
+		e.TransferID = tr.ID
+		e.CreatedAt = tr.CreatedAt
+		record.entries = append(record.entries, len(m.entries))
+		m.entries = append(m.entries, *e)
+	}
+	m.transfers[tr.ID] = record
+
+	return nil
+}
+
+func (m *Memory) GetAccountByNumber(ctx context.Context, number int64) (*entity.Account, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	acc, ok := m.accounts[number]
+	if !ok {
+		return &entity.Account{}, fmt.Errorf("%w: %d", errs.ErrAccountNotFound, number)
+	}
+
+	found := *acc
+	return &found, nil
+}
+
+func (m *Memory) UpdatePassword(ctx context.Context, number int64, encPass string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	acc, ok := m.accounts[number]
+	if !ok {
+		return fmt.Errorf("%w: %d", errs.ErrAccountNotFound, number)
+	}
+	acc.EncryptedPassword = encPass
+	acc.TokenVersion++
+
+	return nil
+}
+
+func (m *Memory) UpdateAccountRole(ctx context.Context, number int64, role entity.Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	acc, ok := m.accounts[number]
+	if !ok {
+		return fmt.Errorf("%w: %d", errs.ErrAccountNotFound, number)
+	}
+	acc.Role = role
+
+	return nil
+}
+
+func (m *Memory) ChangeAccountStatus(ctx context.Context, change *entity.AccountStatusChange) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	acc, ok := m.accounts[change.Number]
+	if !ok {
+		return fmt.Errorf("%w: %d", errs.ErrAccountNotFound, change.Number)
+	}
+	if acc.Status != change.From {
+		return fmt.Errorf("%w: account %d is %s", errs.ErrInvalidStatusChange, change.Number, acc.Status)
+	}
+	if change.To == entity.StatusClosed && acc.Balance != 0 {
+		return fmt.Errorf("%w: account %d", errs.ErrBalanceNotZero, change.Number)
+	}
+
+	acc.Status = change.To
+	m.statuses = append(m.statuses, *change)
+
+	return nil
+}
+
+func (m *Memory) ListAccountStatusChanges(ctx context.Context, number int64) ([]entity.AccountStatusChange, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	changes := make([]entity.AccountStatusChange, 0)
+	for _, c := range m.statuses {
+		if c.Number == number {
+			changes = append(changes, c)
+		}
+	}
+
+	return changes, nil
+}
+
+func (m *Memory) AccountAuthenticity(ctx context.Context, number int64, encPass string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	acc, ok := m.accounts[number]
+	if !ok {
+		return fmt.Errorf("%w: %d", errs.ErrAccountNotFound, number)
+	}
+
+	if !entity.PasswordMatches(acc.EncryptedPassword, encPass) {
+		return errs.ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+func (m *Memory) GetLedgerEntries(ctx context.Context, number int64) ([]entity.LedgerEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := make([]entity.LedgerEntry, 0)
+	for _, e := range m.entries {
+		if e.AccountNumber == number {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+func (m *Memory) ListTransactions(ctx context.Context, filter entity.TransactionFilter) ([]entity.Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	txs := make([]entity.Transaction, 0)
+	for i := len(m.entries) - 1; i >= 0 && len(txs) < filter.Limit; i-- {
+		e := m.entries[i]
+		if e.AccountNumber != filter.Number || !matchesFilter(e, filter) {
+			continue
+		}
+
+		tr := m.transfers[e.TransferID]
+		t := entity.Transaction{
+			ID:           e.ID,
+			TransferID:   e.TransferID,
+			Kind:         tr.kind,
+			Reference:    tr.reference,
+			Direction:    entity.DirectionIn,
+			Amount:       e.Amount,
+			Currency:     e.Currency,
+			Rate:         tr.rate,
+			Counterparty: m.counterparty(e),
+			Balance:      e.Balance,
+			CreatedAt:    e.CreatedAt,
+		}
+		if t.Amount < 0 {
+			t.Direction = entity.DirectionOut
+			t.Amount = -t.Amount
+		}
+		txs = append(txs, t)
+	}
+
+	return txs, nil
+}
+
+func matchesFilter(e entity.LedgerEntry, filter entity.TransactionFilter) bool {
+	amount := e.Amount
+	if amount < 0 {
+		amount = -amount
+	}
+
+	switch {
+	case filter.Before > 0 && e.ID >= filter.Before:
+		return false
+	case !filter.From.IsZero() && e.CreatedAt.Before(filter.From):
+		return false
+	case !filter.To.IsZero() && !e.CreatedAt.Before(filter.To):
+		return false
+	case filter.Direction == entity.DirectionIn && e.Amount <= 0:
+		return false
+	case filter.Direction == entity.DirectionOut && e.Amount >= 0:
+		return false
+	case filter.MinAmount > 0 && amount < filter.MinAmount:
+		return false
+	case filter.MaxAmount > 0 && amount > filter.MaxAmount:
+		return false
+	}
+
+	return true
+}
+
+// counterparty returns the other account of the transfer of e, preferring a
+// real account over the external one.
+func (m *Memory) counterparty(e entity.LedgerEntry) int64 {
+	for _, i := range m.transfers[e.TransferID].entries {
+		other := m.entries[i].AccountNumber
+		if other != e.AccountNumber && other != entity.ExternalAccountNumber {
+			return other
+		}
+	}
+
+	return entity.ExternalAccountNumber
+}
+
+func (m *Memory) GetIdempotencyRecord(ctx context.Context, fromAccount int64, key string) (*entity.IdempotencyRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rec, ok := m.idempotency[idempotencyKey{from: fromAccount, key: key}]
+	if !ok {
+		return nil, errs.ErrIdempotencyKeyNotFound
+	}
+
+	found := *rec
+	return &found, nil
+}
+
+func (m *Memory) CreateIdempotencyRecord(ctx context.Context, rec *entity.IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.idempotency[keyOf(rec)]; ok {
+		return errs.ErrIdempotencyKeyExists
+	}
+
+	stored := *rec
+	stored.Response = nil
+	m.idempotency[keyOf(rec)] = &stored
+
+	return nil
+}
+
+func (m *Memory) CompleteIdempotentTransfer(ctx context.Context, tr *entity.Transfer, rec *entity.IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.idempotency[keyOf(rec)]
+	if !ok || stored.Completed() || !stored.CreatedAt.Equal(rec.CreatedAt) {
+		return errs.ErrIdempotencyKeyNotFound
+	}
+	if err := m.applyTransfer(tr); err != nil {
+		return err
+	}
+	stored.Response = append([]byte{}, rec.Response...)
+
+	return nil
+}
+
+func (m *Memory) ReclaimIdempotencyRecord(ctx context.Context, rec *entity.IdempotencyRecord, staleBefore time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.idempotency[keyOf(rec)]
+	if !ok || stored.Completed() || !stored.CreatedAt.Before(staleBefore) {
+		return errs.ErrIdempotencyKeyInProgress
+	}
+	stored.RequestHash = rec.RequestHash
+	stored.CreatedAt = rec.CreatedAt
+
+	return nil
+}
+
+func (m *Memory) DeleteIdempotencyRecord(ctx context.Context, rec *entity.IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.idempotency[keyOf(rec)]; ok && !stored.Completed() && stored.CreatedAt.Equal(rec.CreatedAt) {
+		delete(m.idempotency, keyOf(rec))
+	}
+
+	return nil
+}
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, tok *entity.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *tok
+	m.tokens[tok.TokenHash] = &stored
+
+	return nil
+}
+
+func (m *Memory) GetRefreshToken(ctx context.Context, tokenHash string) (*entity.RefreshToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tok, ok := m.tokens[tokenHash]
+	if !ok {
+		return nil, errs.ErrInvalidToken
+	}
+
+	found := *tok
+	return &found, nil
+}
+
+func (m *Memory) RotateRefreshToken(ctx context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tok := range m.tokens {
+		if tok.ID != id {
+			continue
+		}
+		if tok.Rotated() || tok.Revoked() {
+			return errs.ErrTokenReused
+		}
+		tok.RotatedAt = at
+		return nil
+	}
+
+	return errs.ErrInvalidToken
+}
+
+func (m *Memory) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tok := range m.tokens {
+		if tok.FamilyID == familyID && !tok.Revoked() {
+			tok.RevokedAt = at
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) RevokeRefreshTokens(ctx context.Context, number int64, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tok := range m.tokens {
+		if tok.Number == number && !tok.Revoked() {
+			tok.RevokedAt = at
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) CreatePasswordResetToken(ctx context.Context, tok *entity.PasswordResetToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *tok
+	m.resets[tok.TokenHash] = &stored
+
+	return nil
+}
+
+func (m *Memory) GetPasswordResetToken(ctx context.Context, tokenHash string) (*entity.PasswordResetToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tok, ok := m.resets[tokenHash]
+	if !ok {
+		return nil, errs.ErrInvalidToken
+	}
+
+	found := *tok
+	return &found, nil
+}
+
+func (m *Memory) UsePasswordResetToken(ctx context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tok := range m.resets {
+		if tok.ID != id {
+			continue
+		}
+		if tok.Used() {
+			return errs.ErrInvalidToken
+		}
+		tok.UsedAt = at
+		return nil
+	}
+
+	return errs.ErrInvalidToken
+}
+
+func (m *Memory) GetMFA(ctx context.Context, number int64) (*entity.MFA, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	mfa, ok := m.mfa[number]
+	if !ok {
+		return nil, errs.ErrMFANotEnrolled
+	}
+	mfa.RecoveryCodes = append([]string(nil), mfa.RecoveryCodes...)
+
+	return &mfa, nil
+}
+
+func (m *Memory) SaveMFA(ctx context.Context, mfa *entity.MFA) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *mfa
+	stored.RecoveryCodes = append([]string(nil), mfa.RecoveryCodes...)
+	m.mfa[mfa.Number] = stored
+
+	return nil
+}
+
+func (m *Memory) DeleteMFA(ctx context.Context, number int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.mfa, number)
+	return nil
+}
+
+func (m *Memory) UseMFAStep(ctx context.Context, number int64, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mfa, ok := m.mfa[number]
+	if !ok || step <= mfa.LastUsedStep {
+		return errs.ErrInvalidMFACode
+	}
+	mfa.LastUsedStep = step
+	m.mfa[number] = mfa
+
+	return nil
+}
+
+func (m *Memory) UseMFARecoveryCode(ctx context.Context, number int64, codeHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mfa, ok := m.mfa[number]
+	if !ok {
+		return errs.ErrInvalidMFACode
+	}
+	for i, h := range mfa.RecoveryCodes {
+		if h == codeHash {
+			mfa.RecoveryCodes = append(mfa.RecoveryCodes[:i:i], mfa.RecoveryCodes[i+1:]...)
+			m.mfa[number] = mfa
+			return nil
+		}
+	}
+
+	return errs.ErrInvalidMFACode
+}
+
+func (m *Memory) CreateScheduledTransfer(ctx context.Context, st *entity.ScheduledTransfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *st
+	m.schedules[st.ID] = &stored
+
+	return nil
+}
+
+func (m *Memory) GetScheduledTransfer(ctx context.Context, id string) (*entity.ScheduledTransfer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	st, ok := m.schedules[id]
+	if !ok {
+		return nil, errs.ErrScheduleNotFound
+	}
+
+	found := *st
+	return &found, nil
+}
+
+func (m *Memory) ListScheduledTransfers(ctx context.Context, number int64) ([]entity.ScheduledTransfer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	schedules := make([]entity.ScheduledTransfer, 0)
+	for _, st := range m.schedules {
+		if st.FromAccount == number {
+			schedules = append(schedules, *st)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].CreatedAt.Before(schedules[j].CreatedAt) })
+
+	return schedules, nil
+}
+
+func (m *Memory) ListDueScheduledTransfers(ctx context.Context, now time.Time, limit int) ([]entity.ScheduledTransfer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	schedules := make([]entity.ScheduledTransfer, 0)
+	for _, st := range m.schedules {
+		if st.Status == entity.ScheduleActive && !st.NextAttemptAt.After(now) {
+			schedules = append(schedules, *st)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].NextAttemptAt.Before(schedules[j].NextAttemptAt) })
+
+	if len(schedules) > limit {
+		schedules = schedules[:limit]
+	}
+	return schedules, nil
+}
+
+func (m *Memory) UpdateScheduledTransfer(ctx context.Context, st *entity.ScheduledTransfer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.schedules[st.ID]; !ok {
+		return errs.ErrScheduleNotFound
+	}
+
+	stored := *st
+	m.schedules[st.ID] = &stored
+
+	return nil
+}
+
+func (m *Memory) DeleteScheduledTransfer(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.schedules[id]; !ok {
+		return errs.ErrScheduleNotFound
+	}
+	delete(m.schedules, id)
+
+	return nil
+}
+
+func (m *Memory) CreateScheduledTransferExecution(ctx context.Context, exec *entity.ScheduledTransferExecution) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	exec.ID = int64(len(m.executions) + 1)
+	m.executions = append(m.executions, *exec)
+
+	return nil
+}
+
+func (m *Memory) ListScheduledTransferExecutions(ctx context.Context, scheduleID string) ([]entity.ScheduledTransferExecution, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	executions := make([]entity.ScheduledTransferExecution, 0)
+	for _, exec := range m.executions {
+		if exec.ScheduleID == scheduleID {
+			executions = append(executions, exec)
+		}
+	}
+
+	return executions, nil
+}
+
+func (m *Memory) GetTransferLimits(ctx context.Context, number int64) (*entity.TransferLimits, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l, ok := m.limits[number]
+	if !ok {
+		l = entity.TransferLimits{Number: number}
+	}
+	return &l, nil
+}
+
+func (m *Memory) SaveTransferLimits(ctx context.Context, limits *entity.TransferLimits) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.limits[limits.Number] = *limits
+	return nil
+}
+
+func (m *Memory) OutgoingTransferStats(ctx context.Context, number int64, since time.Time) (entity.TransferStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.outgoingTransferStats(number, since), nil
+}
+
+// outgoingTransferStats needs the caller to hold the lock.
+func (m *Memory) outgoingTransferStats(number int64, since time.Time) entity.TransferStats {
+	var stats entity.TransferStats
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		e := m.entries[i]
+		if e.CreatedAt.Before(since) {
+			break
+		}
+		if e.AccountNumber != number || e.Amount >= 0 || m.transfers[e.TransferID].kind != entity.KindTransfer {
+			continue
+		}
+		stats.Count++
+		stats.Amount -= e.Amount
+	}
+
+	return stats
+}
+
+func (m *Memory) GetLoginLockout(ctx context.Context, number int64) (*entity.LoginLockout, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l, ok := m.lockouts[number]
+	if !ok {
+		return nil, errs.ErrLockoutNotFound
+	}
+
+	cp := l
+	return &cp, nil
+}
+
+func (m *Memory) AddLoginFailure(ctx context.Context, number int64, now time.Time, window time.Duration) (*entity.LoginLockout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l := m.lockouts[number]
+	l.Number = number
+	if l.Failures == 0 || now.Sub(l.FirstFailureAt) > window {
+		l.Failures = 0
+		l.FirstFailureAt = now
+	}
+	l.Failures++
+	m.lockouts[number] = l
+
+	return &l, nil
+}
+
+func (m *Memory) LockLogin(ctx context.Context, number int64, maxFailures int, lockedUntil time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.lockouts[number]
+	if !ok || l.Failures < maxFailures {
+		return false, nil
+	}
+	l.Failures = 0
+	l.Lockouts++
+	l.LockedUntil = lockedUntil
+	m.lockouts[number] = l
+
+	return true, nil
+}
+
+func (m *Memory) DeleteLoginLockout(ctx context.Context, number int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.lockouts, number)
+	return nil
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close is a no-op; the data lives as long as the process.
+func (m *Memory) Close() error {
+	return nil
+}
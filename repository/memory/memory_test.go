@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+)
+
+func newAccount(t *testing.T, m *Memory, balance int64) int64 {
+	t.Helper()
+
+	acc, err := entity.NewAccount("John", "Doe", "mypassword", balance)
+	if err != nil {
+		t.Fatalf("unexpected error while creating new account: %s", err.Error())
+	}
+
+	number, err := m.CreateAccount(context.Background(), acc)
+	if err != nil {
+		t.Fatalf("unexpected error while storing new account: %s", err.Error())
+	}
+	return number
+}
+
+func balanceOf(t *testing.T, m *Memory, number int64) int64 {
+	t.Helper()
+
+	acc, err := m.GetAccountByNumber(context.Background(), number)
+	if err != nil {
+		t.Fatalf("unexpected error while getting account: %s", err.Error())
+	}
+	return acc.Balance
+}
+
+func TestTransferAmount(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+	from := newAccount(t, m, 100)
+	to := newAccount(t, m, 0)
+
+	if err := m.TransferAmount(ctx, entity.NewTransfer(from, to, 40, entity.DefaultCurrency)); err != nil {
+		t.Fatalf("unexpected error while transferring: %s", err.Error())
+	}
+	if b := balanceOf(t, m, from); b != 60 {
+		t.Errorf("expected balance of sender to be 60, but got %d", b)
+	}
+	if b := balanceOf(t, m, to); b != 40 {
+		t.Errorf("expected balance of receiver to be 40, but got %d", b)
+	}
+
+	entries, _ := m.GetLedgerEntries(ctx, from)
+	if len(entries) != 2 || entries[1].Amount != -40 || entries[1].Balance != 60 {
+		t.Errorf("expected an opening and a debit entry, but got %+v", entries)
+	}
+}
+
+func TestTransferAmountIsAtomic(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+	from := newAccount(t, m, 100)
+	to := newAccount(t, m, 0)
+
+	if err := m.TransferAmount(ctx, entity.NewTransfer(from, to, 101, entity.DefaultCurrency)); err == nil {
+		t.Errorf("expected insufficient balance error")
+	}
+	if err := m.TransferAmount(ctx, entity.NewTransfer(from, 12345678, 10, entity.DefaultCurrency)); err == nil {
+		t.Errorf("expected unknown account error")
+	}
+	if b := balanceOf(t, m, from); b != 100 {
+		t.Errorf("expected failed transfers to leave balance at 100, but got %d", b)
+	}
+	if entries, _ := m.GetLedgerEntries(ctx, from); len(entries) != 1 {
+		t.Errorf("expected failed transfers to write no entries, but got %d", len(entries))
+	}
+}
+
+func TestConcurrentTransfersNeverOverdraw(t *testing.T) {
+	m := New()
+	from := newAccount(t, m, 50)
+	to := newAccount(t, m, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.TransferAmount(context.Background(), entity.NewTransfer(from, to, 1, entity.DefaultCurrency))
+		}()
+	}
+	wg.Wait()
+
+	if b := balanceOf(t, m, from); b != 0 {
+		t.Errorf("expected balance of sender to be 0, but got %d", b)
+	}
+	if b := balanceOf(t, m, to); b != 50 {
+		t.Errorf("expected balance of receiver to be 50, but got %d", b)
+	}
+}
+
+func TestAccountAuthenticityChecksPasswordAgainstHash(t *testing.T) {
+	m := New()
+	ctx := context.Background()
+	number := newAccount(t, m, 0)
+
+	if err := m.AccountAuthenticity(ctx, number, "mypassword"); err != nil {
+		t.Errorf("unexpected error for the right password: %v", err)
+	}
+	if err := m.AccountAuthenticity(ctx, number, "wrongpassword"); !errors.Is(err, errs.ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, but got %v", err)
+	}
+}
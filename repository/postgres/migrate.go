@@ -0,0 +1,279 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the key of the advisory lock held while migrating, so
+// instances starting together apply every migration exactly once.
+const migrationLockID = 7_245_183_921
+
+var (
+	ErrSchemaTooNew     = errors.New("database schema is newer than this build")
+	ErrInvalidMigration = errors.New("invalid migration")
+)
+
+// Migration is a numbered schema change read from migrations/NNNN_name.up.sql
+// and its matching .down.sql file.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		base := strings.TrimPrefix(file, "migrations/")
+		prefix, rest, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, base)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, base)
+		}
+
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version}
+			byVersion[version] = m
+		}
+
+		var name string
+		switch {
+		case strings.HasSuffix(rest, ".up.sql"):
+			name = strings.TrimSuffix(rest, ".up.sql")
+			m.Up = string(content)
+		case strings.HasSuffix(rest, ".down.sql"):
+			name = strings.TrimSuffix(rest, ".down.sql")
+			m.Down = string(content)
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, base)
+		}
+		if m.Name != "" && m.Name != name {
+			return nil, fmt.Errorf("%w: version %d is used by %s and %s", ErrInvalidMigration, version, m.Name, name)
+		}
+		m.Name = name
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("%w: %04d_%s needs both an up and a down file", ErrInvalidMigration, m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration.
+func (pg *Postgres) MigrateUp(ctx context.Context) error {
+	return pg.migrate(ctx, func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error {
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := applyMigration(ctx, conn, m.Up, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+				m.Version, m.Name, time.Now().UTC()); err != nil {
+				return fmt.Errorf("cannot apply migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			slog.InfoContext(ctx, "applied migration", "version", m.Version, "name", m.Name)
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts the latest steps applied migrations.
+func (pg *Postgres) MigrateDown(ctx context.Context, steps int) error {
+	return pg.migrate(ctx, func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error {
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if err := applyMigration(ctx, conn, m.Down, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+				return fmt.Errorf("cannot revert migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			slog.InfoContext(ctx, "reverted migration", "version", m.Version, "name", m.Name)
+			steps--
+		}
+		return nil
+	})
+}
+
+// MigrationStatus lists the known migrations and whether they are applied.
+func (pg *Postgres) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	var status []MigrationStatus
+	err := pg.migrate(ctx, func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error {
+		for _, m := range migrations {
+			at, ok := applied[m.Version]
+			status = append(status, MigrationStatus{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: at})
+		}
+		return nil
+	})
+
+	return status, err
+}
+
+// SchemaCurrent reports whether every migration of this build is applied. It
+// only reads schema_migrations, so it is cheap enough for readiness probes.
+func (pg *Postgres) SchemaCurrent(ctx context.Context) (bool, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := pg.db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return false, err
+		}
+		applied[version] = time.Time{}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	if err := checkKnown(migrations, applied); err != nil {
+		return false, err
+	}
+
+	return len(applied) == len(migrations), nil
+}
+
+// migrate runs fn on a single connection holding the migration lock, after
+// making sure the database has no migration this build does not know.
+func (pg *Postgres) migrate(ctx context.Context, fn func(conn *sql.Conn, migrations []Migration, applied map[int]time.Time) error) (err error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return err
+	}
+
+	conn, err := pg.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("cannot acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, unlockErr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID); unlockErr != nil && err == nil {
+			err = fmt.Errorf("cannot release migration lock: %w", unlockErr)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at timestamp NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("cannot create schema_migrations: %w", err)
+	}
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if err := checkKnown(migrations, applied); err != nil {
+		return err
+	}
+
+	return fn(conn, migrations, applied)
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
+
+// checkKnown fails when the database has a migration applied that is not part
+// of this build, i.e. it was migrated by a newer release.
+func checkKnown(migrations []Migration, applied map[int]time.Time) error {
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+	}
+
+	for version := range applied {
+		if !known[version] {
+			return fmt.Errorf("%w: unknown migration %04d", ErrSchemaTooNew, version)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, script string, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
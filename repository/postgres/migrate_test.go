@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatalf("unexpected error while loading migrations: %s", err.Error())
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("expected migration %d to have version %d, but got %d", i, i+1, m.Version)
+		}
+	}
+}
+
+func TestLoadMigrationsRequiresDownFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0001_create_account.up.sql": {Data: []byte("CREATE TABLE account ();")},
+	}
+
+	if _, err := loadMigrations(fsys); !errors.Is(err, ErrInvalidMigration) {
+		t.Errorf("expected ErrInvalidMigration, but got %v", err)
+	}
+}
+
+func TestCheckKnownRefusesNewerSchema(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}}
+
+	if err := checkKnown(migrations, map[int]time.Time{1: {}}); err != nil {
+		t.Errorf("unexpected error for an older schema: %v", err)
+	}
+	if err := checkKnown(migrations, map[int]time.Time{1: {}, 2: {}, 3: {}}); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("expected ErrSchemaTooNew, but got %v", err)
+	}
+}
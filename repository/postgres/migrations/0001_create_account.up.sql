@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS account (
+	id SERIAL PRIMARY KEY,
+	firstname VARCHAR(50),
+	lastname VARCHAR(50),
+	encrypted_pass VARCHAR(100),
+	number BIGINT UNIQUE NOT NULL,
+	balance BIGINT NOT NULL DEFAULT 0,
+	created_at timestamp,
+	CONSTRAINT number_range CHECK (number BETWEEN 10000000 AND 99999999)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS transfer (
+	id VARCHAR(32) PRIMARY KEY,
+	created_at timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entry (
+	id BIGSERIAL PRIMARY KEY,
+	transfer_id VARCHAR(32) NOT NULL REFERENCES transfer (id),
+	account_number BIGINT NOT NULL,
+	amount BIGINT NOT NULL,
+	balance BIGINT NOT NULL,
+	created_at timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ledger_entry_account_number_idx ON ledger_entry (account_number, id);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS idempotency_key (
+	key VARCHAR(255) PRIMARY KEY,
+	request_hash CHAR(64) NOT NULL,
+	response BYTEA,
+	created_at timestamp NOT NULL
+);
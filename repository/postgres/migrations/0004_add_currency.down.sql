@@ -0,0 +1,3 @@
+ALTER TABLE transfer DROP COLUMN IF EXISTS rate;
+ALTER TABLE ledger_entry DROP COLUMN IF EXISTS currency;
+ALTER TABLE account DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,3 @@
+ALTER TABLE account ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE ledger_entry ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE transfer ADD COLUMN IF NOT EXISTS rate NUMERIC;
@@ -0,0 +1,2 @@
+ALTER TABLE transfer DROP COLUMN IF EXISTS reference;
+ALTER TABLE transfer DROP COLUMN IF EXISTS kind;
@@ -0,0 +1,2 @@
+ALTER TABLE transfer ADD COLUMN IF NOT EXISTS kind VARCHAR(16) NOT NULL DEFAULT 'transfer';
+ALTER TABLE transfer ADD COLUMN IF NOT EXISTS reference VARCHAR(140);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS refresh_token (
+	id VARCHAR(32) PRIMARY KEY,
+	family_id VARCHAR(32) NOT NULL,
+	number BIGINT NOT NULL,
+	token_hash CHAR(64) UNIQUE NOT NULL,
+	expires_at timestamp NOT NULL,
+	created_at timestamp NOT NULL,
+	rotated_at timestamp,
+	revoked_at timestamp
+);
+
+CREATE INDEX IF NOT EXISTS refresh_token_family_id_idx ON refresh_token (family_id);
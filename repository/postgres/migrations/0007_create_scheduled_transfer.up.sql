@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS scheduled_transfer (
+	id VARCHAR(32) PRIMARY KEY,
+	from_account BIGINT NOT NULL,
+	to_account BIGINT NOT NULL,
+	amount BIGINT NOT NULL,
+	currency CHAR(3) NOT NULL,
+	reference VARCHAR(140),
+	frequency VARCHAR(16) NOT NULL,
+	cron_expression VARCHAR(100),
+	start_at timestamp NOT NULL,
+	end_at timestamp,
+	status VARCHAR(16) NOT NULL,
+	next_run_at timestamp NOT NULL,
+	next_attempt_at timestamp NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	max_attempts INT NOT NULL,
+	retry_backoff BIGINT NOT NULL,
+	last_run_at timestamp,
+	created_at timestamp NOT NULL,
+	updated_at timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_transfer_due_idx ON scheduled_transfer (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS scheduled_transfer_from_account_idx ON scheduled_transfer (from_account);
+
+CREATE TABLE IF NOT EXISTS scheduled_transfer_execution (
+	id BIGSERIAL PRIMARY KEY,
+	schedule_id VARCHAR(32) NOT NULL REFERENCES scheduled_transfer (id) ON DELETE CASCADE,
+	run_at timestamp NOT NULL,
+	attempt INT NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	transfer_id VARCHAR(32),
+	error TEXT,
+	executed_at timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_transfer_execution_schedule_id_idx ON scheduled_transfer_execution (schedule_id, id);
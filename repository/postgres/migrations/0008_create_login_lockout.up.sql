@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS login_lockout (
+	number BIGINT PRIMARY KEY,
+	failures INT NOT NULL DEFAULT 0,
+	first_failure_at timestamp,
+	lockouts INT NOT NULL DEFAULT 0,
+	locked_until timestamp
+);
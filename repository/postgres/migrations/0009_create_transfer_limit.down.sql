@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ledger_entry_account_number_created_at_idx;
+DROP TABLE IF EXISTS transfer_limit;
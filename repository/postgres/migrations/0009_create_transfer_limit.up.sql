@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS transfer_limit (
+	number BIGINT PRIMARY KEY,
+	max_single_transfer BIGINT NOT NULL DEFAULT 0,
+	daily_outgoing BIGINT NOT NULL DEFAULT 0,
+	monthly_outgoing BIGINT NOT NULL DEFAULT 0,
+	max_transfers_per_hour INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS ledger_entry_account_number_created_at_idx ON ledger_entry (account_number, created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE account DROP COLUMN IF EXISTS status;
+ALTER TABLE account DROP COLUMN IF EXISTS role;
@@ -0,0 +1,2 @@
+ALTER TABLE account ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'customer';
+ALTER TABLE account ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active';
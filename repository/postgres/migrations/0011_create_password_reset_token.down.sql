@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS refresh_token_number_idx;
+DROP TABLE IF EXISTS password_reset_token;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS password_reset_token (
+	id VARCHAR(32) PRIMARY KEY,
+	number BIGINT NOT NULL,
+	token_hash CHAR(64) UNIQUE NOT NULL,
+	expires_at timestamp NOT NULL,
+	created_at timestamp NOT NULL,
+	used_at timestamp
+);
+
+CREATE INDEX IF NOT EXISTS refresh_token_number_idx ON refresh_token (number);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS account_status_change (
+	id VARCHAR(32) PRIMARY KEY,
+	number BIGINT NOT NULL,
+	from_status VARCHAR(16) NOT NULL,
+	to_status VARCHAR(16) NOT NULL,
+	reason TEXT NOT NULL DEFAULT '',
+	actor VARCHAR(32) NOT NULL,
+	created_at timestamp NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS account_status_change_number_idx ON account_status_change (number, created_at);
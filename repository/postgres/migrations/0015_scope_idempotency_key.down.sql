@@ -0,0 +1,3 @@
+ALTER TABLE idempotency_key DROP CONSTRAINT IF EXISTS idempotency_key_pkey;
+ALTER TABLE idempotency_key ADD PRIMARY KEY (key);
+ALTER TABLE idempotency_key DROP COLUMN IF EXISTS from_account;
@@ -0,0 +1,4 @@
+-- keys are chosen by clients, so they are only unique per sending account
+ALTER TABLE idempotency_key ADD COLUMN IF NOT EXISTS from_account BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE idempotency_key DROP CONSTRAINT IF EXISTS idempotency_key_pkey;
+ALTER TABLE idempotency_key ADD PRIMARY KEY (from_account, key);
@@ -5,86 +5,117 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/XSAM/otelsql"
+	"github.com/lib/pq"
 	"github.com/mohamadafzal06/depository/config"
 	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/repository"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 )
 
-var (
-	ErrTableCreation = errors.New("table creation failed")
-)
+var tracer = otel.Tracer("github.com/mohamadafzal06/depository/repository/postgres")
 
 type Postgres struct {
 	db *sql.DB
 }
 
-func NewPostgres() (*Postgres, error) {
+func NewPostgres(cfg config.DatabaseConfig) (*Postgres, error) {
 	connStr := fmt.Sprintf("postgresql://%s:%s@%s/%s?sslmode=disable",
-		config.DatabaseUser, config.DatabasePass, config.DatabaseAddress, config.DatabaseDBName)
+		cfg.User, cfg.Password, cfg.Address, cfg.Name)
 
-	db, err := sql.Open("postgres", connStr)
+	// every statement, transaction and connection checkout gets a span
+	db, err := otelsql.Open("postgres", connStr, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	return &Postgres{db: db}, nil
 }
 
+func (pg *Postgres) Close() error {
+	return pg.db.Close()
+}
+
+// Init brings the schema up to date. It fails with ErrSchemaTooNew when the
+// database was migrated by a newer release.
 func (pg *Postgres) Init() error {
-	return pg.CreateAccountTable()
+	return pg.MigrateUp(context.Background())
 }
 
-func (pg *Postgres) CreateAccountTable() error {
-	query := `CREATE TABLE IF NOT EXISTS account (
-	id SERIAL PRIMARY KEY,
-	firstname VARCHAR(50),
-	lastname VARCHAR(50),
-	encrypted_pass VARCHAR(50),
-	number SERIAL UNIQUE,
-	balance, 
-	created_at timestamp
-	CONSTRAINT number_range CHECK (number BETWEEN 10000000 AND 99999999)
-	);`
+// withTx runs fn inside a serializable transaction and commits it when fn
+// returns no error.
+func (pg *Postgres) withTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	ctx, span := tracer.Start(ctx, "Postgres.withTx")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
 
-	_, err := pg.db.Exec(query)
+	tx, err := pg.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
 	if err != nil {
-		return ErrTableCreation
+		return err
 	}
 
-	return nil
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			if rerr := tx.Rollback(); rerr != nil {
+				slog.ErrorContext(ctx, "cannot roll back transaction", "error", rerr)
+			}
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	return fn(tx)
 }
 
 func (pg *Postgres) CreateAccount(ctx context.Context, acc *entity.Account) (int64, error) {
-	res, err := pg.db.ExecContext(ctx,
-		"insert into account (firstname, lastname, encrypted_pass, balance) values($1, $2, $3, $4);",
-		acc.FirstName, acc.LastName, acc.EncryptedPassword, acc.Balance)
+	var number int64
+	err := pg.withTx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx,
+			"insert into account (firstname, lastname, encrypted_pass, number, balance, currency, role, status, created_at) values($1, $2, $3, $4, 0, $5, $6, $7, $8) returning number;",
+			acc.FirstName, acc.LastName, acc.EncryptedPassword, acc.Number, acc.Currency, acc.Role, acc.Status, acc.CreatedAt)
+		if err := row.Scan(&number); err != nil {
+			if isUniqueViolation(err) {
+				return fmt.Errorf("%w: %d", errs.ErrAccountExists, acc.Number)
+			}
+			return fmt.Errorf("cannot insert this account into db: %w", err)
+		}
 
-	if err != nil {
-		return -1, fmt.Errorf("cannot insert this account into db: %w", err)
-	}
+		// the opening balance is recorded in the ledger like any other transfer
+		if acc.Balance != 0 {
+			return applyTransfer(ctx, tx, entity.NewDeposit(number, acc.Balance, acc.Currency, "opening balance"))
+		}
 
-	id, err := res.LastInsertId()
+		return nil
+	})
 	if err != nil {
-		return -1, fmt.Errorf("error while getting inserted id: %w", err)
-	}
-	row := pg.db.QueryRowContext(ctx, "select number from account where id=$1", id)
-	var number int64
-	if err = row.Scan(&number); err != nil {
-		return -1, fmt.Errorf("error while scanning number of inserted account: %w", err)
+		return -1, err
 	}
 
 	return number, nil
 }
 
 func (pg *Postgres) GetAccountByNumber(ctx context.Context, number int64) (*entity.Account, error) {
-	row := pg.db.QueryRowContext(ctx, "select (fistname, lastname, balance, created_at) from account where number=$1", number)
+	row := pg.db.QueryRowContext(ctx, "select id, firstname, lastname, number, balance, currency, role, status, created_at, token_version from account where number=$1", number)
 	var acc entity.Account
-	err := row.Scan(&acc.FirstName, &acc.LastName, &acc.Balance, &acc.CreatedAt)
+	err := row.Scan(&acc.ID, &acc.FirstName, &acc.LastName, &acc.Number, &acc.Balance, &acc.Currency, &acc.Role, &acc.Status, &acc.CreatedAt, &acc.TokenVersion)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return &entity.Account{}, fmt.Errorf("account with this number does not exist: %w", err)
+			return &entity.Account{}, fmt.Errorf("%w: %d", errs.ErrAccountNotFound, number)
 		}
 
 		return &entity.Account{}, fmt.Errorf("error while scanning result from db: %w", err)
@@ -93,71 +124,644 @@ func (pg *Postgres) GetAccountByNumber(ctx context.Context, number int64) (*enti
 	return &acc, nil
 }
 
-func (pg *Postgres) DeleteAccount(ctx context.Context, number int64) error {
+func (pg *Postgres) UpdatePassword(ctx context.Context, number int64, encPass string) error {
+	res, err := pg.db.ExecContext(ctx, "UPDATE account SET encrypted_pass = $1, token_version = token_version + 1 WHERE number = $2", encPass, number)
+	if err != nil {
+		return fmt.Errorf("cannot update password of account: %w", err)
+	}
+
+	return expectAffected(res, fmt.Errorf("%w: %d", errs.ErrAccountNotFound, number))
+}
+
+func (pg *Postgres) UpdateAccountRole(ctx context.Context, number int64, role entity.Role) error {
+	res, err := pg.db.ExecContext(ctx, "UPDATE account SET role = $1 WHERE number = $2", role, number)
+	if err != nil {
+		return fmt.Errorf("cannot update role of account: %w", err)
+	}
+
+	return expectAffected(res, fmt.Errorf("%w: %d", errs.ErrAccountNotFound, number))
+}
+
+func (pg *Postgres) ChangeAccountStatus(ctx context.Context, change *entity.AccountStatusChange) error {
+	return pg.withTx(ctx, func(tx *sql.Tx) error {
+		var status entity.AccountStatus
+		var balance int64
+		err := tx.QueryRowContext(ctx, "SELECT status, balance FROM account WHERE number = $1 FOR UPDATE", change.Number).Scan(&status, &balance)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: %d", errs.ErrAccountNotFound, change.Number)
+			}
+			return err
+		}
+		if status != change.From {
+			return fmt.Errorf("%w: account %d is %s", errs.ErrInvalidStatusChange, change.Number, status)
+		}
+		if change.To == entity.StatusClosed && balance != 0 {
+			return fmt.Errorf("%w: account %d", errs.ErrBalanceNotZero, change.Number)
+		}
+
+		return setAccountStatus(ctx, tx, change)
+	})
+}
+
+// setAccountStatus applies and records change, whose account row tx has
+// locked.
+func setAccountStatus(ctx context.Context, tx *sql.Tx, change *entity.AccountStatusChange) error {
+	if _, err := tx.ExecContext(ctx, "UPDATE account SET status = $1 WHERE number = $2", change.To, change.Number); err != nil {
+		return fmt.Errorf("cannot update status of account: %w", err)
+	}
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO account_status_change (id, number, from_status, to_status, reason, actor, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		change.ID, change.Number, change.From, change.To, change.Reason, change.Actor, change.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("cannot insert status change: %w", err)
+	}
+
+	return nil
+}
+
+func (pg *Postgres) ListAccountStatusChanges(ctx context.Context, number int64) ([]entity.AccountStatusChange, error) {
+	rows, err := pg.db.QueryContext(ctx,
+		"SELECT id, number, from_status, to_status, reason, actor, created_at FROM account_status_change WHERE number = $1 ORDER BY created_at", number)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query status changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := make([]entity.AccountStatusChange, 0)
+	for rows.Next() {
+		var c entity.AccountStatusChange
+		if err := rows.Scan(&c.ID, &c.Number, &c.From, &c.To, &c.Reason, &c.Actor, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error while scanning result from db: %w", err)
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+func (pg *Postgres) TransferAmount(ctx context.Context, tr *entity.Transfer) error {
+	return pg.withTx(ctx, func(tx *sql.Tx) error {
+		return applyTransfer(ctx, tx, tr)
+	})
+}
+
+func (pg *Postgres) Deposit(ctx context.Context, tr *entity.Transfer) error {
+	return pg.withTx(ctx, func(tx *sql.Tx) error {
+		return applyTransfer(ctx, tx, tr)
+	})
+}
+
+func (pg *Postgres) Withdraw(ctx context.Context, tr *entity.Transfer) error {
+	return pg.withTx(ctx, func(tx *sql.Tx) error {
+		return applyTransfer(ctx, tx, tr)
+	})
+}
+
+// applyTransfer updates the balance of every account taking part in tr and
+// writes its ledger entries. Entries of the external account only get written
+// to the ledger.
+func applyTransfer(ctx context.Context, tx *sql.Tx, tr *entity.Transfer) error {
+	if from := tr.Sender(); tr.Check != nil && from != entity.ExternalAccountNumber {
+		if _, err := tx.ExecContext(ctx, "SELECT 1 FROM account WHERE number = $1 FOR UPDATE", from); err != nil {
+			return err
+		}
+		err := tr.Check(func(since time.Time) (entity.TransferStats, error) {
+			return outgoingTransferStats(ctx, tx, from, since)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO transfer (id, kind, reference, rate, created_at) VALUES ($1, $2, $3, $4, $5)",
+		tr.ID, tr.Kind, tr.Reference, sql.NullString{String: tr.Rate, Valid: tr.Rate != ""}, tr.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("cannot insert transfer: %w", err)
+	}
+
+	for i := range tr.Entries {
+		e := &tr.Entries[i]
+
+		if e.AccountNumber != entity.ExternalAccountNumber {
+			var balance int64
+			var currency string
+			var status entity.AccountStatus
+			err = tx.QueryRowContext(ctx, "SELECT balance, currency, status FROM account WHERE number = $1 FOR UPDATE", e.AccountNumber).Scan(&balance, &currency, &status)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					return fmt.Errorf("%w: %d", errs.ErrAccountNotFound, e.AccountNumber)
+				}
+				return err
+			}
+
+			if err := repository.CheckAccountStatus(e.AccountNumber, status, e.Amount < 0); err != nil {
+				return err
+			}
+			if e.Currency != currency {
+				return fmt.Errorf("%w: account %d holds %s", errs.ErrCurrencyMismatch, e.AccountNumber, currency)
+			}
+
+			// check that there is enough balance to transfer
+			if balance+e.Amount < 0 {
+				return fmt.Errorf("%w: account %d", errs.ErrInsufficientFunds, e.AccountNumber)
+			}
+
+			e.Balance = balance + e.Amount
+			_, err = tx.ExecContext(ctx, "UPDATE account SET balance = $1 WHERE number = $2", e.Balance, e.AccountNumber)
+			if err != nil {
+				return err
+			}
+			if status == entity.StatusPending && e.Amount > 0 {
+				if err := setAccountStatus(ctx, tx, entity.NewActivation(e.AccountNumber, tr.CreatedAt)); err != nil {
+					return err
+				}
+			}
+		}
+
+		err = tx.QueryRowContext(ctx,
+			"INSERT INTO ledger_entry (transfer_id, account_number, amount, currency, balance, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+			tr.ID, e.AccountNumber, e.Amount, e.Currency, e.Balance, tr.CreatedAt).Scan(&e.ID)
+		if err != nil {
+			return fmt.Errorf("cannot insert ledger entry: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (pg *Postgres) GetLedgerEntries(ctx context.Context, number int64) ([]entity.LedgerEntry, error) {
+	rows, err := pg.db.QueryContext(ctx,
+		"SELECT id, transfer_id, account_number, amount, currency, balance, created_at FROM ledger_entry WHERE account_number = $1 ORDER BY id",
+		number)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]entity.LedgerEntry, 0)
+	for rows.Next() {
+		var e entity.LedgerEntry
+		if err := rows.Scan(&e.ID, &e.TransferID, &e.AccountNumber, &e.Amount, &e.Currency, &e.Balance, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error while scanning result from db: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func (pg *Postgres) ListTransactions(ctx context.Context, filter entity.TransactionFilter) ([]entity.Transaction, error) {
+	conds := []string{"e.account_number = $1"}
+	args := []interface{}{filter.Number}
+	addCond := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.Before > 0 {
+		addCond("e.id < $%d", filter.Before)
+	}
+	if !filter.From.IsZero() {
+		addCond("e.created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCond("e.created_at < $%d", filter.To)
+	}
+	switch filter.Direction {
+	case entity.DirectionIn:
+		conds = append(conds, "e.amount > 0")
+	case entity.DirectionOut:
+		conds = append(conds, "e.amount < 0")
+	}
+	if filter.MinAmount > 0 {
+		addCond("ABS(e.amount) >= $%d", filter.MinAmount)
+	}
+	if filter.MaxAmount > 0 {
+		addCond("ABS(e.amount) <= $%d", filter.MaxAmount)
+	}
+	args = append(args, filter.Limit)
+
+	// the counterparty is the other account of the transfer, preferring a real
+	// account over the external one
+	query := fmt.Sprintf(`SELECT e.id, e.transfer_id, t.kind, COALESCE(t.reference, ''), e.amount, e.currency, COALESCE(t.rate::text, ''), e.balance, e.created_at,
+	COALESCE((SELECT c.account_number FROM ledger_entry c
+		WHERE c.transfer_id = e.transfer_id AND c.account_number <> e.account_number
+		ORDER BY (c.account_number = 0), c.id LIMIT 1), 0)
+	FROM ledger_entry e
+	JOIN transfer t ON t.id = e.transfer_id
+	WHERE %s
+	ORDER BY e.id DESC
+	LIMIT $%d`, strings.Join(conds, " AND "), len(args))
+
+	rows, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	txs := make([]entity.Transaction, 0)
+	for rows.Next() {
+		var t entity.Transaction
+		if err := rows.Scan(&t.ID, &t.TransferID, &t.Kind, &t.Reference, &t.Amount, &t.Currency, &t.Rate, &t.Balance, &t.CreatedAt, &t.Counterparty); err != nil {
+			return nil, fmt.Errorf("error while scanning result from db: %w", err)
+		}
+
+		t.Direction = entity.DirectionIn
+		if t.Amount < 0 {
+			t.Direction = entity.DirectionOut
+			t.Amount = -t.Amount
+		}
+		txs = append(txs, t)
+	}
 
-	_, err := pg.db.ExecContext(ctx, "delete from account where number=$1", number)
+	return txs, rows.Err()
+}
+
+func (pg *Postgres) GetIdempotencyRecord(ctx context.Context, fromAccount int64, key string) (*entity.IdempotencyRecord, error) {
+	row := pg.db.QueryRowContext(ctx, "SELECT from_account, key, request_hash, response, created_at FROM idempotency_key WHERE from_account = $1 AND key = $2", fromAccount, key)
+	var rec entity.IdempotencyRecord
+	err := row.Scan(&rec.FromAccount, &rec.Key, &rec.RequestHash, &rec.Response, &rec.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("account with this number does not exist: %w", err)
+			return nil, errs.ErrIdempotencyKeyNotFound
 		}
-		return fmt.Errorf("cannot delete account by this number: %w", err)
+		return nil, fmt.Errorf("error while scanning result from db: %w", err)
+	}
+
+	return &rec, nil
+}
+
+func (pg *Postgres) CreateIdempotencyRecord(ctx context.Context, rec *entity.IdempotencyRecord) error {
+	_, err := pg.db.ExecContext(ctx,
+		"INSERT INTO idempotency_key (from_account, key, request_hash, created_at) VALUES ($1, $2, $3, $4)",
+		rec.FromAccount, rec.Key, rec.RequestHash, rec.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errs.ErrIdempotencyKeyExists
+		}
+		return fmt.Errorf("cannot insert idempotency key: %w", err)
 	}
 
 	return nil
 }
 
-func (pg *Postgres) TransferAmount(ctx context.Context, from, to, amount int64) error {
-	// begin a transaction
-	tx, err := pg.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+func (pg *Postgres) CompleteIdempotentTransfer(ctx context.Context, tr *entity.Transfer, rec *entity.IdempotencyRecord) error {
+	return pg.withTx(ctx, func(tx *sql.Tx) error {
+		// the update locks the key, so the reservation cannot be reclaimed
+		// while the money moves
+		res, err := tx.ExecContext(ctx,
+			"UPDATE idempotency_key SET response = $1 WHERE from_account = $2 AND key = $3 AND created_at = $4 AND response IS NULL",
+			rec.Response, rec.FromAccount, rec.Key, rec.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("cannot store idempotent response: %w", err)
+		}
+		if err := expectAffected(res, errs.ErrIdempotencyKeyNotFound); err != nil {
+			return err
+		}
+
+		return applyTransfer(ctx, tx, tr)
+	})
+}
+
+func (pg *Postgres) ReclaimIdempotencyRecord(ctx context.Context, rec *entity.IdempotencyRecord, staleBefore time.Time) error {
+	res, err := pg.db.ExecContext(ctx,
+		"UPDATE idempotency_key SET request_hash = $1, created_at = $2 WHERE from_account = $3 AND key = $4 AND response IS NULL AND created_at < $5",
+		rec.RequestHash, rec.CreatedAt, rec.FromAccount, rec.Key, staleBefore)
 	if err != nil {
-		return err
+		return fmt.Errorf("cannot reclaim idempotency key: %w", err)
 	}
 
-	defer func() {
-		if p := recover(); p != nil {
-			tx.Rollback()
-			panic(p)
-		} else if err != nil {
-			tx.Rollback()
-		} else {
-			err = tx.Commit()
+	return expectAffected(res, errs.ErrIdempotencyKeyInProgress)
+}
+
+func (pg *Postgres) DeleteIdempotencyRecord(ctx context.Context, rec *entity.IdempotencyRecord) error {
+	_, err := pg.db.ExecContext(ctx, "DELETE FROM idempotency_key WHERE from_account = $1 AND key = $2 AND created_at = $3 AND response IS NULL", rec.FromAccount, rec.Key, rec.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("cannot delete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+func (pg *Postgres) CreateRefreshToken(ctx context.Context, tok *entity.RefreshToken) error {
+	_, err := pg.db.ExecContext(ctx,
+		"INSERT INTO refresh_token (id, family_id, number, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		tok.ID, tok.FamilyID, tok.Number, tok.TokenHash, tok.ExpiresAt, tok.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("cannot insert refresh token: %w", err)
+	}
+
+	return nil
+}
+
+func (pg *Postgres) GetRefreshToken(ctx context.Context, tokenHash string) (*entity.RefreshToken, error) {
+	row := pg.db.QueryRowContext(ctx,
+		"SELECT id, family_id, number, token_hash, expires_at, created_at, rotated_at, revoked_at FROM refresh_token WHERE token_hash = $1",
+		tokenHash)
+
+	var tok entity.RefreshToken
+	var rotatedAt, revokedAt sql.NullTime
+	err := row.Scan(&tok.ID, &tok.FamilyID, &tok.Number, &tok.TokenHash, &tok.ExpiresAt, &tok.CreatedAt, &rotatedAt, &revokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.ErrInvalidToken
 		}
-	}()
+		return nil, fmt.Errorf("error while scanning result from db: %w", err)
+	}
+	tok.RotatedAt = rotatedAt.Time
+	tok.RevokedAt = revokedAt.Time
 
-	// select the balance of account from
-	var balance1 int64
-	err = tx.QueryRowContext(ctx, "SELECT balance FROM account WHERE number = $1", from).Scan(&balance1)
+	return &tok, nil
+}
+
+func (pg *Postgres) RotateRefreshToken(ctx context.Context, id string, at time.Time) error {
+	res, err := pg.db.ExecContext(ctx,
+		"UPDATE refresh_token SET rotated_at = $1 WHERE id = $2 AND rotated_at IS NULL AND revoked_at IS NULL",
+		at, id)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return fmt.Errorf("cannot rotate refresh token: %w", err)
+	}
+
+	return expectAffected(res, errs.ErrTokenReused)
+}
+
+func (pg *Postgres) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	_, err := pg.db.ExecContext(ctx,
+		"UPDATE refresh_token SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL",
+		at, familyID)
+	if err != nil {
+		return fmt.Errorf("cannot revoke refresh tokens: %w", err)
 	}
 
-	// check that there is enough balance to transfer
-	if balance1 < amount {
-		tx.Rollback()
-		return errors.New("insufficient balance")
+	return nil
+}
+
+func (pg *Postgres) RevokeRefreshTokens(ctx context.Context, number int64, at time.Time) error {
+	_, err := pg.db.ExecContext(ctx,
+		"UPDATE refresh_token SET revoked_at = $1 WHERE number = $2 AND revoked_at IS NULL",
+		at, number)
+	if err != nil {
+		return fmt.Errorf("cannot revoke refresh tokens: %w", err)
 	}
 
-	// update the balance of account from
-	_, err = tx.ExecContext(ctx, "UPDATE account SET balance = balance - $1 WHERE number = $2", amount, from)
+	return nil
+}
+
+func (pg *Postgres) CreatePasswordResetToken(ctx context.Context, tok *entity.PasswordResetToken) error {
+	_, err := pg.db.ExecContext(ctx,
+		"INSERT INTO password_reset_token (id, number, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)",
+		tok.ID, tok.Number, tok.TokenHash, tok.ExpiresAt, tok.CreatedAt)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return fmt.Errorf("cannot insert password reset token: %w", err)
 	}
 
-	// update the balance of account to
-	_, err = tx.ExecContext(ctx, "UPDATE account SET balance = balance + $1 WHERE number = $2", amount, to)
+	return nil
+}
+
+func (pg *Postgres) GetPasswordResetToken(ctx context.Context, tokenHash string) (*entity.PasswordResetToken, error) {
+	row := pg.db.QueryRowContext(ctx,
+		"SELECT id, number, token_hash, expires_at, created_at, used_at FROM password_reset_token WHERE token_hash = $1",
+		tokenHash)
+
+	var tok entity.PasswordResetToken
+	var usedAt sql.NullTime
+	err := row.Scan(&tok.ID, &tok.Number, &tok.TokenHash, &tok.ExpiresAt, &tok.CreatedAt, &usedAt)
 	if err != nil {
-		tx.Rollback()
-		return err
+		if err == sql.ErrNoRows {
+			return nil, errs.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("error while scanning result from db: %w", err)
+	}
+	tok.UsedAt = usedAt.Time
+
+	return &tok, nil
+}
+
+func (pg *Postgres) UsePasswordResetToken(ctx context.Context, id string, at time.Time) error {
+	res, err := pg.db.ExecContext(ctx,
+		"UPDATE password_reset_token SET used_at = $1 WHERE id = $2 AND used_at IS NULL",
+		at, id)
+	if err != nil {
+		return fmt.Errorf("cannot use password reset token: %w", err)
+	}
+
+	return expectAffected(res, errs.ErrInvalidToken)
+}
+
+func (pg *Postgres) GetMFA(ctx context.Context, number int64) (*entity.MFA, error) {
+	mfa := entity.MFA{Number: number}
+	err := pg.db.QueryRowContext(ctx,
+		"SELECT secret, enabled, recovery_codes, last_used_step, created_at FROM mfa WHERE number = $1", number).
+		Scan(&mfa.Secret, &mfa.Enabled, pq.Array(&mfa.RecoveryCodes), &mfa.LastUsedStep, &mfa.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.ErrMFANotEnrolled
+		}
+		return nil, fmt.Errorf("error while scanning result from db: %w", err)
+	}
+
+	return &mfa, nil
+}
+
+func (pg *Postgres) SaveMFA(ctx context.Context, mfa *entity.MFA) error {
+	_, err := pg.db.ExecContext(ctx, `INSERT INTO mfa (number, secret, enabled, recovery_codes, last_used_step, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (number) DO UPDATE SET secret = $2, enabled = $3, recovery_codes = $4, last_used_step = $5, created_at = $6`,
+		mfa.Number, mfa.Secret, mfa.Enabled, pq.Array(mfa.RecoveryCodes), mfa.LastUsedStep, mfa.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("cannot save mfa: %w", err)
+	}
+
+	return nil
+}
+
+func (pg *Postgres) DeleteMFA(ctx context.Context, number int64) error {
+	_, err := pg.db.ExecContext(ctx, "DELETE FROM mfa WHERE number = $1", number)
+	return err
+}
+
+func (pg *Postgres) UseMFAStep(ctx context.Context, number int64, step int64) error {
+	res, err := pg.db.ExecContext(ctx,
+		"UPDATE mfa SET last_used_step = $1 WHERE number = $2 AND last_used_step < $1", step, number)
+	if err != nil {
+		return fmt.Errorf("cannot use mfa step: %w", err)
+	}
+
+	return expectAffected(res, errs.ErrInvalidMFACode)
+}
+
+func (pg *Postgres) UseMFARecoveryCode(ctx context.Context, number int64, codeHash string) error {
+	res, err := pg.db.ExecContext(ctx,
+		"UPDATE mfa SET recovery_codes = array_remove(recovery_codes, $1) WHERE number = $2 AND $1 = ANY(recovery_codes)",
+		codeHash, number)
+	if err != nil {
+		return fmt.Errorf("cannot use recovery code: %w", err)
+	}
+
+	return expectAffected(res, errs.ErrInvalidMFACode)
+}
+
+const scheduledTransferColumns = `id, from_account, to_account, amount, currency, reference, frequency, cron_expression,
+	start_at, end_at, status, next_run_at, next_attempt_at, attempts, max_attempts, retry_backoff, last_run_at, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanScheduledTransfer(row rowScanner) (*entity.ScheduledTransfer, error) {
+	var st entity.ScheduledTransfer
+	var reference, cronExpression sql.NullString
+	var endAt, lastRunAt sql.NullTime
+	var backoff int64
+
+	err := row.Scan(&st.ID, &st.FromAccount, &st.ToAccount, &st.Amount, &st.Currency, &reference, &st.Frequency, &cronExpression,
+		&st.StartAt, &endAt, &st.Status, &st.NextRunAt, &st.NextAttemptAt, &st.Attempts, &st.MaxAttempts, &backoff, &lastRunAt,
+		&st.CreatedAt, &st.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	st.Reference = reference.String
+	st.CronExpression = cronExpression.String
+	st.EndAt = endAt.Time
+	st.LastRunAt = lastRunAt.Time
+	st.RetryBackoff = time.Duration(backoff)
+
+	return &st, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func (pg *Postgres) CreateScheduledTransfer(ctx context.Context, st *entity.ScheduledTransfer) error {
+	_, err := pg.db.ExecContext(ctx, "INSERT INTO scheduled_transfer ("+scheduledTransferColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`,
+		st.ID, st.FromAccount, st.ToAccount, st.Amount, st.Currency, st.Reference, st.Frequency, st.CronExpression,
+		st.StartAt, nullTime(st.EndAt), st.Status, st.NextRunAt, st.NextAttemptAt, st.Attempts, st.MaxAttempts,
+		int64(st.RetryBackoff), nullTime(st.LastRunAt), st.CreatedAt, st.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("cannot insert scheduled transfer: %w", err)
+	}
+
+	return nil
+}
+
+func (pg *Postgres) GetScheduledTransfer(ctx context.Context, id string) (*entity.ScheduledTransfer, error) {
+	row := pg.db.QueryRowContext(ctx, "SELECT "+scheduledTransferColumns+" FROM scheduled_transfer WHERE id = $1", id)
+	st, err := scanScheduledTransfer(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errs.ErrScheduleNotFound
+		}
+		return nil, fmt.Errorf("error while scanning result from db: %w", err)
+	}
+
+	return st, nil
+}
+
+func (pg *Postgres) ListScheduledTransfers(ctx context.Context, number int64) ([]entity.ScheduledTransfer, error) {
+	return pg.queryScheduledTransfers(ctx,
+		"SELECT "+scheduledTransferColumns+" FROM scheduled_transfer WHERE from_account = $1 ORDER BY created_at", number)
+}
+
+func (pg *Postgres) ListDueScheduledTransfers(ctx context.Context, now time.Time, limit int) ([]entity.ScheduledTransfer, error) {
+	return pg.queryScheduledTransfers(ctx,
+		"SELECT "+scheduledTransferColumns+" FROM scheduled_transfer WHERE status = $1 AND next_attempt_at <= $2 ORDER BY next_attempt_at LIMIT $3",
+		entity.ScheduleActive, now, limit)
+}
+
+func (pg *Postgres) queryScheduledTransfers(ctx context.Context, query string, args ...interface{}) ([]entity.ScheduledTransfer, error) {
+	rows, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query scheduled transfers: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := make([]entity.ScheduledTransfer, 0)
+	for rows.Next() {
+		st, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning result from db: %w", err)
+		}
+		schedules = append(schedules, *st)
+	}
+
+	return schedules, rows.Err()
+}
+
+func (pg *Postgres) UpdateScheduledTransfer(ctx context.Context, st *entity.ScheduledTransfer) error {
+	res, err := pg.db.ExecContext(ctx, `UPDATE scheduled_transfer SET amount = $2, reference = $3, frequency = $4, cron_expression = $5,
+		start_at = $6, end_at = $7, status = $8, next_run_at = $9, next_attempt_at = $10, attempts = $11, max_attempts = $12,
+		retry_backoff = $13, last_run_at = $14, updated_at = $15
+		WHERE id = $1`,
+		st.ID, st.Amount, st.Reference, st.Frequency, st.CronExpression, st.StartAt, nullTime(st.EndAt), st.Status,
+		st.NextRunAt, st.NextAttemptAt, st.Attempts, st.MaxAttempts, int64(st.RetryBackoff), nullTime(st.LastRunAt), st.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("cannot update scheduled transfer: %w", err)
+	}
+
+	return expectAffected(res, errs.ErrScheduleNotFound)
+}
+
+func (pg *Postgres) DeleteScheduledTransfer(ctx context.Context, id string) error {
+	res, err := pg.db.ExecContext(ctx, "DELETE FROM scheduled_transfer WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("cannot delete scheduled transfer: %w", err)
+	}
+
+	return expectAffected(res, errs.ErrScheduleNotFound)
+}
+
+func (pg *Postgres) CreateScheduledTransferExecution(ctx context.Context, exec *entity.ScheduledTransferExecution) error {
+	err := pg.db.QueryRowContext(ctx, `INSERT INTO scheduled_transfer_execution (schedule_id, run_at, attempt, status, transfer_id, error, executed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		exec.ScheduleID, exec.RunAt, exec.Attempt, exec.Status, exec.TransferID, exec.Error, exec.ExecutedAt).Scan(&exec.ID)
+	if err != nil {
+		return fmt.Errorf("cannot insert scheduled transfer execution: %w", err)
 	}
 
-	// commit the transaction
-	err = tx.Commit()
+	return nil
+}
+
+func (pg *Postgres) ListScheduledTransferExecutions(ctx context.Context, scheduleID string) ([]entity.ScheduledTransferExecution, error) {
+	rows, err := pg.db.QueryContext(ctx,
+		`SELECT id, schedule_id, run_at, attempt, status, COALESCE(transfer_id, ''), COALESCE(error, ''), executed_at
+		FROM scheduled_transfer_execution WHERE schedule_id = $1 ORDER BY id`, scheduleID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query scheduled transfer executions: %w", err)
+	}
+	defer rows.Close()
+
+	executions := make([]entity.ScheduledTransferExecution, 0)
+	for rows.Next() {
+		var exec entity.ScheduledTransferExecution
+		err := rows.Scan(&exec.ID, &exec.ScheduleID, &exec.RunAt, &exec.Attempt, &exec.Status, &exec.TransferID, &exec.Error, &exec.ExecutedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error while scanning result from db: %w", err)
+		}
+		executions = append(executions, exec)
+	}
+
+	return executions, rows.Err()
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// expectAffected returns notFound when res did not touch any row.
+func expectAffected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
+	if n == 0 {
+		return notFound
+	}
 
 	return nil
 }
@@ -168,14 +772,118 @@ func (pg *Postgres) AccountAuthenticity(ctx context.Context, number int64, encPa
 	err := row.Scan(&trulyPass)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("account with this number does not exist: %w", err)
+			return fmt.Errorf("%w: %d", errs.ErrAccountNotFound, number)
 		}
 		return fmt.Errorf("error while scanning result from db: %w", err)
 	}
 
-	if encPass != trulyPass {
-		return fmt.Errorf("the given pass is not correct: %w", err)
+	if !entity.PasswordMatches(trulyPass, encPass) {
+		return errs.ErrInvalidCredentials
 	}
 
 	return nil
 }
+
+func (pg *Postgres) Ping(ctx context.Context) error {
+	return pg.db.PingContext(ctx)
+}
+
+// Stats returns the connection pool statistics.
+func (pg *Postgres) Stats() sql.DBStats {
+	return pg.db.Stats()
+}
+
+func (pg *Postgres) GetLoginLockout(ctx context.Context, number int64) (*entity.LoginLockout, error) {
+	l := &entity.LoginLockout{Number: number}
+	var firstFailureAt, lockedUntil sql.NullTime
+	err := pg.db.QueryRowContext(ctx, `SELECT failures, first_failure_at, lockouts, locked_until FROM login_lockout WHERE number = $1`, number).
+		Scan(&l.Failures, &firstFailureAt, &l.Lockouts, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrLockoutNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.FirstFailureAt = firstFailureAt.Time
+	l.LockedUntil = lockedUntil.Time
+
+	return l, nil
+}
+
+func (pg *Postgres) AddLoginFailure(ctx context.Context, number int64, now time.Time, window time.Duration) (*entity.LoginLockout, error) {
+	l := &entity.LoginLockout{Number: number}
+	var firstFailureAt, lockedUntil sql.NullTime
+	// the row is locked by the upsert, so concurrent failures all count
+	err := pg.db.QueryRowContext(ctx, `INSERT INTO login_lockout (number, failures, first_failure_at, lockouts)
+	VALUES ($1, 1, $2, 0)
+	ON CONFLICT (number) DO UPDATE SET
+		failures = CASE WHEN login_lockout.failures = 0 OR login_lockout.first_failure_at < $3 THEN 1 ELSE login_lockout.failures + 1 END,
+		first_failure_at = CASE WHEN login_lockout.failures = 0 OR login_lockout.first_failure_at < $3 THEN $2 ELSE login_lockout.first_failure_at END
+	RETURNING failures, first_failure_at, lockouts, locked_until`,
+		number, now, now.Add(-window)).Scan(&l.Failures, &firstFailureAt, &l.Lockouts, &lockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot record failed login: %w", err)
+	}
+	l.FirstFailureAt = firstFailureAt.Time
+	l.LockedUntil = lockedUntil.Time
+
+	return l, nil
+}
+
+func (pg *Postgres) LockLogin(ctx context.Context, number int64, maxFailures int, lockedUntil time.Time) (bool, error) {
+	res, err := pg.db.ExecContext(ctx, `UPDATE login_lockout SET failures = 0, lockouts = lockouts + 1, locked_until = $1
+	WHERE number = $2 AND failures >= $3`, lockedUntil, number, maxFailures)
+	if err != nil {
+		return false, fmt.Errorf("cannot lock account: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (pg *Postgres) DeleteLoginLockout(ctx context.Context, number int64) error {
+	_, err := pg.db.ExecContext(ctx, `DELETE FROM login_lockout WHERE number = $1`, number)
+	return err
+}
+
+func (pg *Postgres) GetTransferLimits(ctx context.Context, number int64) (*entity.TransferLimits, error) {
+	l := &entity.TransferLimits{Number: number}
+	err := pg.db.QueryRowContext(ctx, `SELECT max_single_transfer, daily_outgoing, monthly_outgoing, max_transfers_per_hour
+	FROM transfer_limit WHERE number = $1`, number).
+		Scan(&l.MaxSingleTransfer, &l.DailyOutgoing, &l.MonthlyOutgoing, &l.MaxTransfersPerHour)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (pg *Postgres) SaveTransferLimits(ctx context.Context, l *entity.TransferLimits) error {
+	_, err := pg.db.ExecContext(ctx, `INSERT INTO transfer_limit (number, max_single_transfer, daily_outgoing, monthly_outgoing, max_transfers_per_hour)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (number) DO UPDATE SET max_single_transfer = $2, daily_outgoing = $3, monthly_outgoing = $4, max_transfers_per_hour = $5`,
+		l.Number, l.MaxSingleTransfer, l.DailyOutgoing, l.MonthlyOutgoing, l.MaxTransfersPerHour)
+	return err
+}
+
+func (pg *Postgres) OutgoingTransferStats(ctx context.Context, number int64, since time.Time) (entity.TransferStats, error) {
+	return outgoingTransferStats(ctx, pg.db, number, since)
+}
+
+// rowQuerier is a *sql.DB or a *sql.Tx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func outgoingTransferStats(ctx context.Context, q rowQuerier, number int64, since time.Time) (entity.TransferStats, error) {
+	var stats entity.TransferStats
+	err := q.QueryRowContext(ctx, `SELECT COUNT(*), COALESCE(SUM(-le.amount), 0)
+	FROM ledger_entry le JOIN transfer t ON t.id = le.transfer_id
+	WHERE le.account_number = $1 AND le.amount < 0 AND t.kind = $2 AND le.created_at >= $3`,
+		number, entity.KindTransfer, since).Scan(&stats.Count, &stats.Amount)
+
+	return stats, err
+}
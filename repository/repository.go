@@ -2,14 +2,126 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
 )
 
 type Repository interface {
 	CreateAccount(ctx context.Context, acc *entity.Account) (int64, error)
-	DeleteAccount(ctx context.Context, number int64) error
-	TransferAmount(ctx context.Context, from, to, amount int64) error
+	TransferAmount(ctx context.Context, tr *entity.Transfer) error
+	Deposit(ctx context.Context, tr *entity.Transfer) error
+	Withdraw(ctx context.Context, tr *entity.Transfer) error
 	GetAccountByNumber(ctx context.Context, number int64) (*entity.Account, error)
+	// UpdatePassword stores a new password hash and bumps the token version
+	// of the account.
+	UpdatePassword(ctx context.Context, number int64, encPass string) error
+	UpdateAccountRole(ctx context.Context, number int64, role entity.Role) error
+	// ChangeAccountStatus moves an account from change.From to change.To and
+	// records the change. It fails with errs.ErrInvalidStatusChange when the
+	// account is no longer in change.From, and with errs.ErrBalanceNotZero
+	// when closing an account that holds money.
+	ChangeAccountStatus(ctx context.Context, change *entity.AccountStatusChange) error
+	ListAccountStatusChanges(ctx context.Context, number int64) ([]entity.AccountStatusChange, error)
 	AccountAuthenticity(ctx context.Context, number int64, encPass string) error
+	GetLedgerEntries(ctx context.Context, number int64) ([]entity.LedgerEntry, error)
+	ListTransactions(ctx context.Context, filter entity.TransactionFilter) ([]entity.Transaction, error)
+	GetIdempotencyRecord(ctx context.Context, fromAccount int64, key string) (*entity.IdempotencyRecord, error)
+	CreateIdempotencyRecord(ctx context.Context, rec *entity.IdempotencyRecord) error
+	// CompleteIdempotentTransfer applies tr and stores rec.Response in one
+	// transaction. It fails with errs.ErrIdempotencyKeyNotFound when the
+	// reservation rec stands for has been released or reclaimed.
+	CompleteIdempotentTransfer(ctx context.Context, tr *entity.Transfer, rec *entity.IdempotencyRecord) error
+	// ReclaimIdempotencyRecord takes over a reservation that is still not
+	// completed and was made before staleBefore, by making rec the
+	// reservation. It fails with errs.ErrIdempotencyKeyInProgress otherwise.
+	ReclaimIdempotencyRecord(ctx context.Context, rec *entity.IdempotencyRecord, staleBefore time.Time) error
+	// DeleteIdempotencyRecord releases the reservation rec stands for, unless
+	// it has been completed or reclaimed.
+	DeleteIdempotencyRecord(ctx context.Context, rec *entity.IdempotencyRecord) error
+	CreateRefreshToken(ctx context.Context, tok *entity.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*entity.RefreshToken, error)
+	// RotateRefreshToken marks an active token as used. It fails with
+	// errs.ErrTokenReused when the token was already rotated or revoked.
+	RotateRefreshToken(ctx context.Context, id string, at time.Time) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error
+	CreateScheduledTransfer(ctx context.Context, st *entity.ScheduledTransfer) error
+	GetScheduledTransfer(ctx context.Context, id string) (*entity.ScheduledTransfer, error)
+	ListScheduledTransfers(ctx context.Context, number int64) ([]entity.ScheduledTransfer, error)
+	// ListDueScheduledTransfers returns active schedules whose next attempt
+	// is at or before now, oldest first.
+	ListDueScheduledTransfers(ctx context.Context, now time.Time, limit int) ([]entity.ScheduledTransfer, error)
+	UpdateScheduledTransfer(ctx context.Context, st *entity.ScheduledTransfer) error
+	DeleteScheduledTransfer(ctx context.Context, id string) error
+	CreateScheduledTransferExecution(ctx context.Context, exec *entity.ScheduledTransferExecution) error
+	ListScheduledTransferExecutions(ctx context.Context, scheduleID string) ([]entity.ScheduledTransferExecution, error)
+	// RevokeRefreshTokens revokes every refresh token of an account.
+	RevokeRefreshTokens(ctx context.Context, number int64, at time.Time) error
+	CreatePasswordResetToken(ctx context.Context, tok *entity.PasswordResetToken) error
+	// GetPasswordResetToken fails with errs.ErrInvalidToken when there is no
+	// token with this hash.
+	GetPasswordResetToken(ctx context.Context, tokenHash string) (*entity.PasswordResetToken, error)
+	// UsePasswordResetToken marks a token used; it fails with
+	// errs.ErrInvalidToken when it already is.
+	UsePasswordResetToken(ctx context.Context, id string, at time.Time) error
+	// GetMFA fails with errs.ErrMFANotEnrolled when the account has no second
+	// factor.
+	GetMFA(ctx context.Context, number int64) (*entity.MFA, error)
+	SaveMFA(ctx context.Context, mfa *entity.MFA) error
+	DeleteMFA(ctx context.Context, number int64) error
+	// UseMFAStep records step as the last used TOTP step; it fails with
+	// errs.ErrInvalidMFACode unless step is later than the recorded one.
+	UseMFAStep(ctx context.Context, number int64, step int64) error
+	// UseMFARecoveryCode removes a recovery code; it fails with
+	// errs.ErrInvalidMFACode when the account has no such code.
+	UseMFARecoveryCode(ctx context.Context, number int64, codeHash string) error
+	// GetTransferLimits returns the limits set for an account; all of them
+	// are zero when none are set.
+	GetTransferLimits(ctx context.Context, number int64) (*entity.TransferLimits, error)
+	SaveTransferLimits(ctx context.Context, limits *entity.TransferLimits) error
+	// OutgoingTransferStats sums the transfers, not withdrawals, that the
+	// account sent since the given time.
+	OutgoingTransferStats(ctx context.Context, number int64, since time.Time) (entity.TransferStats, error)
+	// GetLoginLockout fails with errs.ErrLockoutNotFound when the account has no
+	// failed logins on record.
+	GetLoginLockout(ctx context.Context, number int64) (*entity.LoginLockout, error)
+	// AddLoginFailure counts a failed login at now in one atomic step,
+	// starting the count over when the first failure on record is older than
+	// window, and returns the updated record.
+	AddLoginFailure(ctx context.Context, number int64, now time.Time, window time.Duration) (*entity.LoginLockout, error)
+	// LockLogin locks the account until lockedUntil and starts its failure
+	// count over, but only while it has at least maxFailures failures, so
+	// concurrent failures lock it once. It reports whether it locked.
+	LockLogin(ctx context.Context, number int64, maxFailures int, lockedUntil time.Time) (bool, error)
+	DeleteLoginLockout(ctx context.Context, number int64) error
+	// Ping reports whether the storage can serve requests.
+	Ping(ctx context.Context) error
+	// Close releases the underlying storage. The repository must not be used
+	// afterwards.
+	Close() error
+}
+
+// SchemaChecker is implemented by repositories with a versioned schema.
+type SchemaChecker interface {
+	// SchemaCurrent reports whether every known migration is applied.
+	SchemaCurrent(ctx context.Context) (bool, error)
+}
+
+// CheckAccountStatus tells whether money can leave (send) or reach account
+// number in status. The service checks it up front, and repositories check it
+// again on the locked row, since the status may have changed in between.
+func CheckAccountStatus(number int64, status entity.AccountStatus, send bool) error {
+	switch {
+	case status == entity.StatusFrozen:
+		return fmt.Errorf("%w: %d", errs.ErrAccountFrozen, number)
+	case status == entity.StatusClosed:
+		return fmt.Errorf("%w: %d", errs.ErrAccountClosed, number)
+	case send && !status.CanSend():
+		return fmt.Errorf("%w: %d is %s", errs.ErrAccountInactive, number, status)
+	case !send && !status.CanReceive():
+		return fmt.Errorf("%w: %d is %s", errs.ErrAccountInactive, number, status)
+	}
+	return nil
 }
@@ -1,34 +1,269 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
 	"github.com/mohamadafzal06/depository/param"
+	"github.com/mohamadafzal06/depository/repository"
 )
 
+// AuthConfig configures token signing. When Keys is empty tokens are signed
+// with HS256 using SignKey; otherwise ActiveKeyID selects the key that signs
+// new tokens and the other keys only verify.
 type AuthConfig struct {
 	SignKey               string
+	Keys                  []KeyConfig
+	ActiveKeyID           string
 	AccessExpirationTime  time.Duration
 	RefreshExpirationTime time.Duration
 	AccessSubject         string
 	RefreshSubject        string
+	// MFASubject marks the short-lived tokens that stand between the password
+	// and the second factor of a login.
+	MFASubject        string
+	MFAExpirationTime time.Duration
+	// AdminKey is accepted as a bearer token with the admin role; it is
+	// disabled while empty.
+	AdminKey string
 }
 
 type Auth struct {
 	config AuthConfig
+	keys   *KeySet
+	repo   repository.Repository
 }
 
-func NewAuth(cfg AuthConfig) Auth {
-	return Auth{
+const defaultKeyID = "default"
+
+func NewAuth(cfg AuthConfig, r repository.Repository) (*Auth, error) {
+	keyConfigs, activeID := cfg.Keys, cfg.ActiveKeyID
+	if len(keyConfigs) == 0 {
+		keyConfigs = []KeyConfig{{ID: defaultKeyID, Algorithm: jwt.SigningMethodHS256.Alg(), Secret: cfg.SignKey}}
+		activeID = defaultKeyID
+	}
+
+	keys := make([]*SigningKey, 0, len(keyConfigs))
+	for _, kc := range keyConfigs {
+		key, err := LoadSigningKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load signing key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	ks, err := NewKeySet(activeID, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Auth{
 		config: cfg,
+		keys:   ks,
+		repo:   r,
+	}, nil
+}
+
+// JWKS returns the public keys other services can verify tokens with.
+func (a Auth) JWKS() JWKS {
+	return a.keys.JWKS()
+}
+
+func (a Auth) Config() AuthConfig {
+	return a.config
+}
+
+// Login issues an access token and the first refresh token of a new token
+// family for an account whose password has already been checked.
+func (a Auth) Login(ctx context.Context, req param.LoginRequest) (resp param.LoginResponse, err error) {
+	ctx, end := startSpan(ctx, "Auth.Login")
+	defer func() { end(err) }()
+
+	return a.issueTokens(ctx, req.Number, entity.NewID())
+}
+
+// Authenticate returns the claims of an access token. The token is checked
+// against its account as well, so it stops working as soon as the account is
+// frozen or closed or its password is changed.
+func (a Auth) Authenticate(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := a.ParseToken(tokenString)
+	if err != nil || claims.Subject != a.config.AccessSubject {
+		return nil, errs.ErrInvalidToken
+	}
+
+	acc, err := a.repo.GetAccountByNumber(ctx, claims.Number)
+	if err != nil {
+		return nil, err
 	}
+	if err := checkLoginStatus(acc); err != nil {
+		return nil, err
+	}
+	if claims.TokenVersion != acc.TokenVersion {
+		return nil, errs.ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// checkLoginStatus tells whether acc may log in and use its tokens.
+func checkLoginStatus(acc *entity.Account) error {
+	switch acc.Status {
+	case entity.StatusFrozen:
+		return fmt.Errorf("%w: %d", errs.ErrAccountFrozen, acc.Number)
+	case entity.StatusClosed:
+		return fmt.Errorf("%w: %d", errs.ErrAccountClosed, acc.Number)
+	}
+	return nil
+}
+
+// CreateMFAToken issues the token of a login whose password has been checked
+// but whose second factor has not.
+func (a Auth) CreateMFAToken(number int64) (string, error) {
+	token, err := a.createToken(entity.NewID(), number, "", 0, a.config.MFASubject, a.config.MFAExpirationTime)
+	if err != nil {
+		return "", fmt.Errorf("cannot login: %w", err)
+	}
+	return token, nil
+}
+
+// ParseMFAToken returns the account number of an MFA token.
+func (a Auth) ParseMFAToken(tokenString string) (int64, error) {
+	claims, err := a.ParseToken(tokenString)
+	if err != nil || a.config.MFASubject == "" || claims.Subject != a.config.MFASubject {
+		return 0, errs.ErrInvalidToken
+	}
+	return claims.Number, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh token
+// can be used once; presenting one that has already been rotated means it
+// leaked, so the whole family is revoked.
+func (a Auth) Refresh(ctx context.Context, req param.RefreshTokenRequest) (resp param.LoginResponse, err error) {
+	ctx, end := startSpan(ctx, "Auth.Refresh")
+	defer func() { end(err) }()
+
+	tok, err := a.lookupRefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		return param.LoginResponse{Status: param.LoginUnsuccessful}, err
+	}
+
+	now := time.Now().UTC()
+	if tok.Rotated() && !tok.Revoked() {
+		if err := a.repo.RevokeRefreshTokenFamily(ctx, tok.FamilyID, now); err != nil {
+			return param.LoginResponse{Status: param.LoginUnsuccessful}, fmt.Errorf("cannot revoke token family: %w", err)
+		}
+		return param.LoginResponse{Status: param.LoginUnsuccessful}, errs.ErrTokenReused
+	}
+	if tok.Revoked() || tok.Expired(now) {
+		return param.LoginResponse{Status: param.LoginUnsuccessful}, errs.ErrInvalidToken
+	}
+
+	// a concurrent refresh with the same token loses the race and is treated
+	// as reuse as well
+	if err := a.repo.RotateRefreshToken(ctx, tok.ID, now); err != nil {
+		if errors.Is(err, errs.ErrTokenReused) {
+			if rerr := a.repo.RevokeRefreshTokenFamily(ctx, tok.FamilyID, now); rerr != nil {
+				return param.LoginResponse{Status: param.LoginUnsuccessful}, fmt.Errorf("cannot revoke token family: %w", rerr)
+			}
+		}
+		return param.LoginResponse{Status: param.LoginUnsuccessful}, err
+	}
+
+	return a.issueTokens(ctx, tok.Number, tok.FamilyID)
+}
+
+// Logout revokes the token family of the given refresh token.
+func (a Auth) Logout(ctx context.Context, req param.LogoutRequest) (err error) {
+	ctx, end := startSpan(ctx, "Auth.Logout")
+	defer func() { end(err) }()
+
+	tok, err := a.lookupRefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	if err := a.repo.RevokeRefreshTokenFamily(ctx, tok.FamilyID, time.Now().UTC()); err != nil {
+		return fmt.Errorf("cannot revoke token family: %w", err)
+	}
+
+	return nil
+}
+
+func (a Auth) lookupRefreshToken(ctx context.Context, tokenString string) (*entity.RefreshToken, error) {
+	claims, err := a.ParseToken(tokenString)
+	if err != nil || claims.Subject != a.config.RefreshSubject {
+		return nil, errs.ErrInvalidToken
+	}
+
+	tok, err := a.repo.GetRefreshToken(ctx, hashToken(tokenString))
+	if err != nil {
+		return nil, err
+	}
+	if tok.ID != claims.ID || tok.Number != claims.Number {
+		return nil, errs.ErrInvalidToken
+	}
+
+	return tok, nil
+}
+
+// issueTokens reads the role of the account every time so that a role change
+// takes effect on the next refresh, and a closed account cannot refresh.
+func (a Auth) issueTokens(ctx context.Context, number int64, familyID string) (param.LoginResponse, error) {
+	acc, err := a.repo.GetAccountByNumber(ctx, number)
+	if err != nil {
+		return param.LoginResponse{Status: param.LoginUnsuccessful}, fmt.Errorf("cannot login: %w", err)
+	}
+	if err := checkLoginStatus(acc); err != nil {
+		return param.LoginResponse{Status: param.LoginUnsuccessful}, fmt.Errorf("cannot login: %w", err)
+	}
+
+	access, err := a.createAccessToken(acc)
+	if err != nil {
+		return access, err
+	}
+
+	id := entity.NewID()
+	now := time.Now().UTC()
+	refreshString, err := a.createToken(id, number, "", 0, a.config.RefreshSubject, a.config.RefreshExpirationTime)
+	if err != nil {
+		return param.LoginResponse{Status: param.LoginUnsuccessful}, fmt.Errorf("cannot login: %w", err)
+	}
+
+	err = a.repo.CreateRefreshToken(ctx, &entity.RefreshToken{
+		ID:        id,
+		FamilyID:  familyID,
+		Number:    number,
+		TokenHash: hashToken(refreshString),
+		ExpiresAt: now.Add(a.config.RefreshExpirationTime),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return param.LoginResponse{Status: param.LoginUnsuccessful}, fmt.Errorf("cannot store refresh token: %w", err)
+	}
+
+	access.RefreshToken = refreshString
+	return access, nil
+}
+
+func hashToken(tokenString string) string {
+	sum := sha256.Sum256([]byte(tokenString))
+	return hex.EncodeToString(sum[:])
 }
 
 func (a Auth) CreateAccessToken(req param.LoginRequest) (param.LoginResponse, error) {
-	tokenString, err := a.createToken(req.Number, a.config.AccessSubject, a.config.AccessExpirationTime)
+	return a.createAccessToken(&entity.Account{Number: req.Number, Role: entity.RoleCustomer})
+}
+
+func (a Auth) createAccessToken(acc *entity.Account) (param.LoginResponse, error) {
+	tokenString, err := a.createToken(entity.NewID(), acc.Number, acc.Role, acc.TokenVersion, a.config.AccessSubject, a.config.AccessExpirationTime)
 	if err != nil {
 		return param.LoginResponse{TokenString: "", Status: param.LoginUnsuccessful}, fmt.Errorf("cannot login: %w", err)
 	}
@@ -38,7 +273,7 @@ func (a Auth) CreateAccessToken(req param.LoginRequest) (param.LoginResponse, er
 
 func (a Auth) CreateRefreshToken(req param.LoginRequest) (param.LoginResponse, error) {
 
-	tokenString, err := a.createToken(req.Number, a.config.RefreshSubject, a.config.RefreshExpirationTime)
+	tokenString, err := a.createToken(entity.NewID(), req.Number, "", 0, a.config.RefreshSubject, a.config.RefreshExpirationTime)
 	if err != nil {
 		return param.LoginResponse{TokenString: "", Status: param.LoginUnsuccessful}, fmt.Errorf("cannot login: %w", err)
 	}
@@ -50,34 +285,34 @@ func (a Auth) ParseToken(bearerToken string) (*Claims, error) {
 
 	tokenStr := strings.Replace(bearerToken, "Bearer ", "", 1)
 
-	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(a.config.SignKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, a.keys.keyFunc)
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
 		return claims, nil
-	} else {
-		return nil, err
 	}
+
+	return nil, errs.ErrInvalidToken
 }
 
-func (a Auth) createToken(number int64, subject string, expireDuration time.Duration) (string, error) {
+func (a Auth) createToken(id string, number int64, role entity.Role, version int, subject string, expireDuration time.Duration) (string, error) {
 
 	// set our claims
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
 			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expireDuration)),
 		},
-		Number: number,
+		Number:       number,
+		Role:         role,
+		TokenVersion: version,
 	}
 
-	// TODO - add sign method to config
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := accessToken.SignedString([]byte(a.config.SignKey))
+	tokenString, err := a.keys.sign(claims)
 	if err != nil {
 		return "", err
 	}
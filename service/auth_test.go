@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+	"github.com/mohamadafzal06/depository/repository/memory"
+)
+
+// newTestAuth returns an Auth backed by a repository holding the customer
+// account 12345678.
+func newTestAuth(t *testing.T) *Auth {
+	t.Helper()
+
+	repo := memory.New()
+	if _, err := repo.CreateAccount(context.Background(), &entity.Account{Number: 12345678, Role: entity.RoleCustomer}); err != nil {
+		t.Fatalf("unexpected error while creating account: %s", err.Error())
+	}
+
+	auth, err := NewAuth(AuthConfig{
+		SignKey:               "secret",
+		AccessExpirationTime:  time.Minute,
+		RefreshExpirationTime: time.Hour,
+		AccessSubject:         "at",
+		RefreshSubject:        "rt",
+	}, repo)
+	if err != nil {
+		t.Fatalf("unexpected error while creating auth: %s", err.Error())
+	}
+	return auth
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	auth := newTestAuth(t)
+	ctx := context.Background()
+
+	login, err := auth.Login(ctx, param.LoginRequest{Number: 12345678})
+	if err != nil {
+		t.Fatalf("unexpected error while logging in: %s", err.Error())
+	}
+	if login.TokenString == "" || login.RefreshToken == "" {
+		t.Fatalf("expected access and refresh tokens, but got %+v", login)
+	}
+
+	refreshed, err := auth.Refresh(ctx, param.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("unexpected error while refreshing: %s", err.Error())
+	}
+	if refreshed.RefreshToken == login.RefreshToken {
+		t.Errorf("expected refresh token to be rotated")
+	}
+
+	// reusing the first token revokes the whole family, including the new one
+	if _, err := auth.Refresh(ctx, param.RefreshTokenRequest{RefreshToken: login.RefreshToken}); !errors.Is(err, errs.ErrTokenReused) {
+		t.Errorf("expected ErrTokenReused, but got %v", err)
+	}
+	if _, err := auth.Refresh(ctx, param.RefreshTokenRequest{RefreshToken: refreshed.RefreshToken}); !errors.Is(err, errs.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken after family revocation, but got %v", err)
+	}
+}
+
+func TestLogoutRevokesRefreshToken(t *testing.T) {
+	auth := newTestAuth(t)
+	ctx := context.Background()
+
+	login, _ := auth.Login(ctx, param.LoginRequest{Number: 12345678})
+	if err := auth.Logout(ctx, param.LogoutRequest{RefreshToken: login.RefreshToken}); err != nil {
+		t.Fatalf("unexpected error while logging out: %s", err.Error())
+	}
+
+	if _, err := auth.Refresh(ctx, param.RefreshTokenRequest{RefreshToken: login.RefreshToken}); err == nil {
+		t.Errorf("expected refresh after logout to fail")
+	}
+	if _, err := auth.Refresh(ctx, param.RefreshTokenRequest{RefreshToken: login.TokenString}); !errors.Is(err, errs.ErrInvalidToken) {
+		t.Errorf("expected access token to be rejected as refresh token, but got %v", err)
+	}
+}
+
+func TestAccessTokenCarriesCurrentRole(t *testing.T) {
+	auth := newTestAuth(t)
+	ctx := context.Background()
+
+	login, err := auth.Login(ctx, param.LoginRequest{Number: 12345678})
+	if err != nil {
+		t.Fatalf("unexpected error while logging in: %s", err.Error())
+	}
+	if claims, err := auth.ParseToken(login.TokenString); err != nil || claims.Role != entity.RoleCustomer {
+		t.Fatalf("expected a customer token, but got %+v, %v", claims, err)
+	}
+
+	if err := auth.repo.UpdateAccountRole(ctx, 12345678, entity.RoleTeller); err != nil {
+		t.Fatalf("unexpected error while changing role: %s", err.Error())
+	}
+	refreshed, err := auth.Refresh(ctx, param.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	if err != nil {
+		t.Fatalf("unexpected error while refreshing: %s", err.Error())
+	}
+	if claims, err := auth.ParseToken(refreshed.TokenString); err != nil || claims.Role != entity.RoleTeller {
+		t.Errorf("expected the refreshed token to carry the new role, but got %+v, %v", claims, err)
+	}
+}
@@ -2,11 +2,32 @@ package service
 
 import (
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/mohamadafzal06/depository/entity"
 )
 
 type Claims struct {
 	jwt.RegisteredClaims
 	Number int64
+	// Role is only set on access tokens; tokens issued before roles existed
+	// carry none and count as customer tokens.
+	Role entity.Role `json:"role,omitempty"`
+	// TokenVersion is the token version of the account when an access token
+	// was issued.
+	TokenVersion int `json:"ver,omitempty"`
+}
+
+// HasRole reports whether the token was issued to an account of one of roles.
+func (c Claims) HasRole(roles ...entity.Role) bool {
+	role := c.Role
+	if role == "" {
+		role = entity.RoleCustomer
+	}
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
 }
 
 func (c Claims) Valid() error {
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/mohamadafzal06/depository/errs"
+)
+
+const cursorPrefix = "tx:"
+
+// encodeCursor hides the ledger entry ID the next page starts after, so
+// clients cannot depend on its format.
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errs.ErrInvalidCursor
+	}
+
+	s := string(b)
+	if !strings.HasPrefix(s, cursorPrefix) {
+		return 0, errs.ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(s, cursorPrefix), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errs.ErrInvalidCursor
+	}
+
+	return id, nil
+}
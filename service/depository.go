@@ -1,29 +1,86 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"context"
+	"encoding/json"
 
 	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/exchange"
+	"github.com/mohamadafzal06/depository/notify"
 	"github.com/mohamadafzal06/depository/param"
 	"github.com/mohamadafzal06/depository/repository"
 )
 
 type Depository struct {
-	repo repository.Repository
+	repo    repository.Repository
+	rates   exchange.RateProvider
+	lockout LockoutPolicy
+	limits  map[string]entity.TransferLimits
+	rules   *RulesEngine
+
+	passwords PasswordPolicy
+	notifier  notify.Notifier
+	resetTTL  time.Duration
+	mfa       MFAPolicy
 }
 
-func NewDepository(r repository.Repository) *Depository {
+// NewDepository creates the depository service. rates may be nil when all
+// accounts share a currency.
+func NewDepository(r repository.Repository, rates exchange.RateProvider) *Depository {
 	return &Depository{
-		repo: r,
+		repo:    r,
+		rates:   rates,
+		lockout: DefaultLockoutPolicy,
+		rules:   NewRulesEngine(DefaultTransferRules()...),
+
+		passwords: DefaultPasswordPolicy,
+		resetTTL:  defaultResetTokenTTL,
+		mfa:       DefaultMFAPolicy,
 	}
 }
 
-func (s *Depository) CreateAccount(ctx context.Context, req param.CreateAccountRequest) (param.CreateAccountResponse, error) {
+// maxAccountNumberAttempts is how many random account numbers CreateAccount
+// tries before giving up.
+const maxAccountNumberAttempts = 5
+
+func (s *Depository) CreateAccount(ctx context.Context, req param.CreateAccountRequest) (resp param.CreateAccountResponse, err error) {
+	ctx, end := startSpan(ctx, "Depository.CreateAccount")
+	defer func() { end(err) }()
+
+	if req.Balance < 0 {
+		return param.CreateAccountResponse{}, fmt.Errorf("%w: balance cannot be negative", errs.ErrInvalidRequest)
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = entity.DefaultCurrency
+	}
+	if !entity.ValidCurrency(currency) {
+		return param.CreateAccountResponse{}, fmt.Errorf("%w: %s", errs.ErrUnsupportedCurrency, currency)
+	}
+
+	if err := s.passwords.Check(req.Password); err != nil {
+		return param.CreateAccountResponse{}, err
+	}
+
 	acc, err := entity.NewAccount(req.FistName, req.LastName, req.Password, req.Balance)
+	if err != nil {
+		return param.CreateAccountResponse{}, err
+	}
+	acc.Currency = currency
 
+	// account numbers are random, so a taken one is drawn again
 	number, err := s.repo.CreateAccount(ctx, acc)
+	for attempt := 1; errors.Is(err, errs.ErrAccountExists) && attempt < maxAccountNumberAttempts; attempt++ {
+		acc.Number = entity.RandomNumber()
+		number, err = s.repo.CreateAccount(ctx, acc)
+	}
 	if err != nil {
 		return param.CreateAccountResponse{}, fmt.Errorf("cannot create this account: %w", err)
 	}
@@ -32,12 +89,16 @@ func (s *Depository) CreateAccount(ctx context.Context, req param.CreateAccountR
 		FistName: req.FistName,
 		LastName: req.LastName,
 		Number:   number,
+		Currency: currency,
 	}
 
 	return response, nil
 }
 
-func (s *Depository) GetAccountByNumber(ctx context.Context, req param.GetAccountByNumberRequest) (param.GetAccountByNumberResponse, error) {
+func (s *Depository) GetAccountByNumber(ctx context.Context, req param.GetAccountByNumberRequest) (resp param.GetAccountByNumberResponse, err error) {
+	ctx, end := startSpan(ctx, "Depository.GetAccountByNumber")
+	defer func() { end(err) }()
+
 	acc, err := s.repo.GetAccountByNumber(ctx, req.Number)
 	if err != nil {
 		return param.GetAccountByNumberResponse{}, fmt.Errorf("cannot get account by this number: %w", err)
@@ -45,36 +106,327 @@ func (s *Depository) GetAccountByNumber(ctx context.Context, req param.GetAccoun
 	response := param.GetAccountByNumberResponse{
 		FistName:  acc.FirstName,
 		LastName:  acc.LastName,
+		Number:    acc.Number,
 		Balance:   acc.Balance,
+		Currency:  acc.Currency,
+		Role:      acc.Role,
+		Status:    acc.Status,
 		CreatedAt: acc.CreatedAt,
 	}
 
 	return response, nil
 }
 
-func (s *Depository) DeleteAccount(ctx context.Context, req param.DeleteAccountRequest) error {
-	err := s.repo.DeleteAccount(ctx, req.Number)
-	if err != nil {
-		return fmt.Errorf("cannot get account by this number: %w", err)
+// TransferAmount moves money between two accounts. Requests carrying an
+// idempotency key are executed at most once.
+func (s *Depository) TransferAmount(ctx context.Context, req param.TransferAmountRequest) (resp param.TransferAmountResponse, err error) {
+	ctx, end := startSpan(ctx, "Depository.TransferAmount")
+	defer func() { end(err) }()
+
+	if req.IdempotencyKey != "" {
+		return s.idempotentTransfer(ctx, req)
 	}
 
-	return nil
+	return s.transferAmount(ctx, req, nil)
 }
 
-func (s *Depository) TransferAmount(ctx context.Context, req param.TransferAmountRequest) (param.TransferAmountResponse, error) {
-	err := s.repo.TransferAmount(ctx, req.FromAccount, req.ToAccount, req.Amount)
+// transferAmount moves the money of req. When rec is not nil the response is
+// stored with it in the transaction of the transfer.
+func (s *Depository) transferAmount(ctx context.Context, req param.TransferAmountRequest, rec *entity.IdempotencyRecord) (param.TransferAmountResponse, error) {
+	if req.Amount <= 0 {
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, errs.ErrInvalidAmount
+	}
+	if req.FromAccount == req.ToAccount {
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, errs.ErrSameAccount
+	}
+	if len(req.Reference) > entity.MaxReferenceLength {
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, fmt.Errorf("%w: reference is longer than %d characters", errs.ErrInvalidRequest, entity.MaxReferenceLength)
+	}
+	// scheduled runs were checked when the schedule was set up
+	if !req.Scheduled {
+		if err := s.checkTransferMFA(ctx, req.FromAccount, req.Amount, req.TOTPCode); err != nil {
+			return param.TransferAmountResponse{Status: param.Unsuccessful}, err
+		}
+	}
+
+	tr, err := s.newTransfer(ctx, req)
+	if err != nil {
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, fmt.Errorf("transfer money failed: %w", err)
+	}
+	if !tr.Balanced() {
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, errs.ErrUnbalancedTransfer
+	}
+	tr.Reference = req.Reference
+
+	if err := s.checkTransferRules(ctx, tr, req.FromAccount, req.ToAccount); err != nil {
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, fmt.Errorf("transfer money failed: %w", err)
+	}
+
+	credit := tr.Entries[len(tr.Entries)-1]
+	response := param.TransferAmountResponse{
+		Status:           param.Successful,
+		TransferID:       tr.ID,
+		Amount:           req.Amount,
+		Currency:         tr.Entries[0].Currency,
+		CreditedAmount:   credit.Amount,
+		CreditedCurrency: credit.Currency,
+		Rate:             tr.Rate,
+	}
+
+	if rec == nil {
+		err = s.repo.TransferAmount(ctx, tr)
+	} else if rec.Response, err = json.Marshal(response); err == nil {
+		err = s.repo.CompleteIdempotentTransfer(ctx, tr, rec)
+	}
+	if errors.Is(err, errs.ErrLimitExceeded) {
+		slog.WarnContext(ctx, "transfer rejected", "from_account", req.FromAccount, "to_account", req.ToAccount, "amount", req.Amount, "error", err)
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, fmt.Errorf("transfer money failed: %w", err)
+	}
 	if err != nil {
+		slog.WarnContext(ctx, "transfer failed", "from_account", req.FromAccount, "to_account", req.ToAccount, "amount", req.Amount, "error", err)
 		return param.TransferAmountResponse{Status: param.Unsuccessful}, fmt.Errorf("transfer money failed: %w", err)
 	}
-	return param.TransferAmountResponse{Status: param.Successful}, nil
+	slog.InfoContext(ctx, "transfer completed", "transfer_id", tr.ID, "from_account", req.FromAccount, "to_account", req.ToAccount, "amount", req.Amount)
+
+	return response, nil
+}
+
+// newTransfer builds the transfer for req, converting the amount through the
+// rate provider when the accounts hold different currencies.
+func (s *Depository) newTransfer(ctx context.Context, req param.TransferAmountRequest) (*entity.Transfer, error) {
+	from, err := s.repo.GetAccountByNumber(ctx, req.FromAccount)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.repo.GetAccountByNumber(ctx, req.ToAccount)
+	if err != nil {
+		return nil, err
+	}
+	if err := repository.CheckAccountStatus(from.Number, from.Status, true); err != nil {
+		return nil, err
+	}
+	if err := repository.CheckAccountStatus(to.Number, to.Status, false); err != nil {
+		return nil, err
+	}
+
+	if req.Currency != "" && req.Currency != from.Currency {
+		return nil, fmt.Errorf("%w: %s", errs.ErrCurrencyMismatch, req.Currency)
+	}
+	if from.Currency == to.Currency {
+		return entity.NewTransfer(from.Number, to.Number, req.Amount, from.Currency), nil
+	}
+
+	if s.rates == nil {
+		return nil, fmt.Errorf("%w: %s/%s", errs.ErrRateUnavailable, from.Currency, to.Currency)
+	}
+	rate, err := s.rates.Rate(ctx, from.Currency, to.Currency)
+	if err != nil {
+		return nil, err
+	}
+	converted, err := exchange.Convert(req.Amount, from.Currency, to.Currency, rate)
+	if err != nil {
+		return nil, err
+	}
+	if converted <= 0 {
+		return nil, fmt.Errorf("%w: amount is too small to convert", errs.ErrInvalidAmount)
+	}
+
+	return entity.NewExchangeTransfer(from.Number, req.Amount, from.Currency, to.Number, converted, to.Currency, exchange.FormatRate(rate)), nil
+}
+
+func (s *Depository) Deposit(ctx context.Context, req param.DepositRequest) (resp param.DepositResponse, err error) {
+	ctx, end := startSpan(ctx, "Depository.Deposit")
+	defer func() { end(err) }()
+
+	acc, err := s.checkExternalMovement(ctx, req.Number, req.Amount, req.Currency, req.Reference, false)
+	if err != nil {
+		return param.DepositResponse{Status: param.Unsuccessful}, fmt.Errorf("deposit failed: %w", err)
+	}
+
+	tr := entity.NewDeposit(acc.Number, req.Amount, acc.Currency, req.Reference)
+	if err := s.repo.Deposit(ctx, tr); err != nil {
+		return param.DepositResponse{Status: param.Unsuccessful}, fmt.Errorf("deposit failed: %w", err)
+	}
+
+	credit := tr.Entries[len(tr.Entries)-1]
+	return param.DepositResponse{
+		Status:     param.Successful,
+		TransferID: tr.ID,
+		Amount:     req.Amount,
+		Currency:   acc.Currency,
+		Balance:    credit.Balance,
+	}, nil
+}
+
+func (s *Depository) Withdraw(ctx context.Context, req param.WithdrawRequest) (resp param.WithdrawResponse, err error) {
+	ctx, end := startSpan(ctx, "Depository.Withdraw")
+	defer func() { end(err) }()
+
+	acc, err := s.checkExternalMovement(ctx, req.Number, req.Amount, req.Currency, req.Reference, true)
+	if err != nil {
+		return param.WithdrawResponse{Status: param.Unsuccessful}, fmt.Errorf("withdrawal failed: %w", err)
+	}
+
+	tr := entity.NewWithdrawal(acc.Number, req.Amount, acc.Currency, req.Reference)
+	if err := s.repo.Withdraw(ctx, tr); err != nil {
+		return param.WithdrawResponse{Status: param.Unsuccessful}, fmt.Errorf("withdrawal failed: %w", err)
+	}
+
+	debit := tr.Entries[0]
+	return param.WithdrawResponse{
+		Status:     param.Successful,
+		TransferID: tr.ID,
+		Amount:     req.Amount,
+		Currency:   acc.Currency,
+		Balance:    debit.Balance,
+	}, nil
+}
+
+// checkExternalMovement validates a deposit or a withdrawal (send) and returns
+// the account it applies to.
+func (s *Depository) checkExternalMovement(ctx context.Context, number, amount int64, currency, reference string, send bool) (*entity.Account, error) {
+	if amount <= 0 {
+		return nil, errs.ErrInvalidAmount
+	}
+	if len(reference) > entity.MaxReferenceLength {
+		return nil, fmt.Errorf("%w: reference is longer than %d characters", errs.ErrInvalidRequest, entity.MaxReferenceLength)
+	}
+
+	acc, err := s.repo.GetAccountByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if err := repository.CheckAccountStatus(acc.Number, acc.Status, send); err != nil {
+		return nil, err
+	}
+	if currency != "" && currency != acc.Currency {
+		return nil, fmt.Errorf("%w: %s", errs.ErrCurrencyMismatch, currency)
+	}
+
+	return acc, nil
+}
+
+func (s *Depository) GetLedgerEntries(ctx context.Context, req param.GetLedgerEntriesRequest) (param.GetLedgerEntriesResponse, error) {
+	entries, err := s.repo.GetLedgerEntries(ctx, req.Number)
+	if err != nil {
+		return param.GetLedgerEntriesResponse{}, fmt.Errorf("cannot get ledger entries of this account: %w", err)
+	}
+
+	return param.GetLedgerEntriesResponse{Entries: entries}, nil
+}
+
+const (
+	defaultTransactionsLimit = 20
+	maxTransactionsLimit     = 100
+)
+
+func (s *Depository) GetTransactions(ctx context.Context, req param.GetTransactionsRequest) (resp param.GetTransactionsResponse, err error) {
+	ctx, end := startSpan(ctx, "Depository.GetTransactions")
+	defer func() { end(err) }()
+
+	filter := entity.TransactionFilter{
+		Number:    req.Number,
+		From:      req.From,
+		To:        req.To,
+		Direction: req.Direction,
+		MinAmount: req.MinAmount,
+		MaxAmount: req.MaxAmount,
+		Limit:     req.Limit,
+	}
+
+	if filter.Direction != "" && filter.Direction != entity.DirectionIn && filter.Direction != entity.DirectionOut {
+		return param.GetTransactionsResponse{}, fmt.Errorf("%w: invalid direction %q", errs.ErrInvalidRequest, req.Direction)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultTransactionsLimit
+	}
+	if filter.Limit > maxTransactionsLimit {
+		filter.Limit = maxTransactionsLimit
+	}
+	if req.Cursor != "" {
+		before, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return param.GetTransactionsResponse{}, err
+		}
+		filter.Before = before
+	}
+
+	// ask for one more row than needed to know whether there is a next page
+	filter.Limit++
+	txs, err := s.repo.ListTransactions(ctx, filter)
+	if err != nil {
+		return param.GetTransactionsResponse{}, fmt.Errorf("cannot get transactions of this account: %w", err)
+	}
+
+	response := param.GetTransactionsResponse{Transactions: txs}
+	if len(txs) == filter.Limit {
+		response.Transactions = txs[:len(txs)-1]
+		response.NextCursor = encodeCursor(response.Transactions[len(response.Transactions)-1].ID)
+	}
+
+	return response, nil
+}
+
+// ReconcileAccount derives the balance of an account from its ledger entries
+// and checks it against the stored balance.
+func (s *Depository) ReconcileAccount(ctx context.Context, req param.ReconcileAccountRequest) (param.ReconcileAccountResponse, error) {
+	acc, err := s.repo.GetAccountByNumber(ctx, req.Number)
+	if err != nil {
+		return param.ReconcileAccountResponse{}, fmt.Errorf("cannot get account by this number: %w", err)
+	}
+
+	entries, err := s.repo.GetLedgerEntries(ctx, req.Number)
+	if err != nil {
+		return param.ReconcileAccountResponse{}, fmt.Errorf("cannot get ledger entries of this account: %w", err)
+	}
+
+	var ledgerBalance int64
+	for _, e := range entries {
+		ledgerBalance += e.Amount
+	}
+
+	return param.ReconcileAccountResponse{
+		Number:        req.Number,
+		Balance:       acc.Balance,
+		LedgerBalance: ledgerBalance,
+		Consistent:    acc.Balance == ledgerBalance,
+	}, nil
 }
 
 // TODO: should moved to auth service
-func (s *Depository) CheckPass(ctx context.Context, req param.LoginRequest) (param.PassCheckRespone, error) {
-	err := s.repo.AccountAuthenticity(ctx, req.Number, req.Password)
+func (s *Depository) CheckPass(ctx context.Context, req param.LoginRequest) (resp param.PassCheckRespone, err error) {
+	ctx, end := startSpan(ctx, "Depository.CheckPass")
+	defer func() { end(err) }()
+
+	now := time.Now().UTC()
+	lockout, err := s.loginLockout(ctx, req.Number)
 	if err != nil {
 		return param.PassCheckRespone{Truly: false}, err
 	}
+	if lockout.Locked(now) {
+		return param.PassCheckRespone{Truly: false}, fmt.Errorf("%w until %s", errs.ErrAccountLocked, lockout.LockedUntil.Format(time.RFC3339))
+	}
+
+	err = s.repo.AccountAuthenticity(ctx, req.Number, req.Password)
+	if err != nil {
+		// do not tell callers which account numbers exist
+		if errors.Is(err, errs.ErrAccountNotFound) {
+			return param.PassCheckRespone{Truly: false}, errs.ErrInvalidCredentials
+		}
+		if errors.Is(err, errs.ErrInvalidCredentials) {
+			if lerr := s.recordLoginFailure(ctx, req.Number, now); lerr != nil {
+				slog.ErrorContext(ctx, "cannot record failed login", "number", req.Number, "error", lerr)
+			}
+		}
+		return param.PassCheckRespone{Truly: false}, err
+	}
+
+	// a successful login starts the backoff over
+	if lockout.Failures > 0 || lockout.Lockouts > 0 {
+		if err := s.repo.DeleteLoginLockout(ctx, req.Number); err != nil {
+			slog.ErrorContext(ctx, "cannot reset failed logins", "number", req.Number, "error", err)
+		}
+	}
 
 	return param.PassCheckRespone{Truly: true}, nil
 }
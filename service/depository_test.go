@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/exchange"
+	"github.com/mohamadafzal06/depository/param"
+	"github.com/mohamadafzal06/depository/repository"
+	"github.com/mohamadafzal06/depository/repository/memory"
+)
+
+func newTestDepository(t *testing.T, balances ...int64) (*Depository, []int64) {
+	t.Helper()
+
+	srv := NewDepository(memory.New(), nil)
+	numbers := make([]int64, 0, len(balances))
+	for _, b := range balances {
+		resp, err := srv.CreateAccount(context.Background(), param.CreateAccountRequest{
+			FistName: "John",
+			LastName: "Doe",
+			Password: "mypassword",
+			Balance:  b,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error while creating account: %s", err.Error())
+		}
+		numbers = append(numbers, resp.Number)
+	}
+
+	return srv, numbers
+}
+
+// collidingRepository reports the first collisions account numbers as taken.
+type collidingRepository struct {
+	repository.Repository
+	collisions int
+}
+
+func (r *collidingRepository) CreateAccount(ctx context.Context, acc *entity.Account) (int64, error) {
+	if r.collisions > 0 {
+		r.collisions--
+		return -1, fmt.Errorf("%w: %d", errs.ErrAccountExists, acc.Number)
+	}
+	return r.Repository.CreateAccount(ctx, acc)
+}
+
+func TestCreateAccountRetriesTakenNumbers(t *testing.T) {
+	ctx := context.Background()
+	req := param.CreateAccountRequest{FistName: "John", LastName: "Doe", Password: "mypassword"}
+
+	srv := NewDepository(&collidingRepository{Repository: memory.New(), collisions: maxAccountNumberAttempts - 1}, nil)
+	if _, err := srv.CreateAccount(ctx, req); err != nil {
+		t.Errorf("expected a free number to be found, but got %v", err)
+	}
+
+	srv = NewDepository(&collidingRepository{Repository: memory.New(), collisions: maxAccountNumberAttempts}, nil)
+	if _, err := srv.CreateAccount(ctx, req); !errors.Is(err, errs.ErrAccountExists) {
+		t.Errorf("expected ErrAccountExists once every attempt collides, but got %v", err)
+	}
+}
+
+func TestTransferAmountKeepsLedgerConsistent(t *testing.T) {
+	srv, n := newTestDepository(t, 100, 0)
+	ctx := context.Background()
+
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 30}); err != nil {
+		t.Fatalf("unexpected error while transferring: %s", err.Error())
+	}
+
+	for _, number := range n {
+		resp, err := srv.ReconcileAccount(ctx, param.ReconcileAccountRequest{Number: number})
+		if err != nil {
+			t.Fatalf("unexpected error while reconciling: %s", err.Error())
+		}
+		if !resp.Consistent {
+			t.Errorf("expected account %d to be consistent, but got %+v", number, resp)
+		}
+	}
+}
+
+func TestTransferAmountRejectsInvalidRequests(t *testing.T) {
+	srv, n := newTestDepository(t, 100)
+	ctx := context.Background()
+
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[0], Amount: 10}); !errors.Is(err, errs.ErrSameAccount) {
+		t.Errorf("expected ErrSameAccount, but got %v", err)
+	}
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[0] + 1, Amount: 0}); !errors.Is(err, errs.ErrInvalidAmount) {
+		t.Errorf("expected ErrInvalidAmount, but got %v", err)
+	}
+}
+
+func TestTransferAmountConvertsCurrency(t *testing.T) {
+	rates, _ := exchange.NewStaticProvider(map[string]map[string]string{"USD": {"EUR": "0.9"}})
+	srv := NewDepository(memory.New(), rates)
+	ctx := context.Background()
+
+	usd, _ := srv.CreateAccount(ctx, param.CreateAccountRequest{Password: "mypassword", Balance: 1000, Currency: "USD"})
+	eur, _ := srv.CreateAccount(ctx, param.CreateAccountRequest{Password: "mypassword", Currency: "EUR"})
+
+	resp, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: usd.Number, ToAccount: eur.Number, Amount: 500})
+	if err != nil {
+		t.Fatalf("unexpected error while transferring: %s", err.Error())
+	}
+	if resp.CreditedAmount != 450 || resp.CreditedCurrency != "EUR" || resp.Rate != "0.9" {
+		t.Errorf("expected 450 EUR at 0.9 to be credited, but got %+v", resp)
+	}
+
+	acc, _ := srv.GetAccountByNumber(ctx, param.GetAccountByNumberRequest{Number: eur.Number})
+	if acc.Balance != 450 || acc.Currency != "EUR" {
+		t.Errorf("expected balance of 450 EUR, but got %d %s", acc.Balance, acc.Currency)
+	}
+
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: usd.Number, ToAccount: eur.Number, Amount: 1, Currency: "EUR"}); !errors.Is(err, errs.ErrCurrencyMismatch) {
+		t.Errorf("expected ErrCurrencyMismatch, but got %v", err)
+	}
+}
+
+func TestDepositAndWithdraw(t *testing.T) {
+	srv, n := newTestDepository(t, 0)
+	ctx := context.Background()
+
+	if _, err := srv.Deposit(ctx, param.DepositRequest{Number: n[0], Amount: 100, Reference: "salary"}); err != nil {
+		t.Fatalf("unexpected error while depositing: %s", err.Error())
+	}
+	resp, err := srv.Withdraw(ctx, param.WithdrawRequest{Number: n[0], Amount: 30, Reference: "atm"})
+	if err != nil {
+		t.Fatalf("unexpected error while withdrawing: %s", err.Error())
+	}
+	if resp.Balance != 70 {
+		t.Errorf("expected balance to be 70, but got %d", resp.Balance)
+	}
+	if _, err := srv.Withdraw(ctx, param.WithdrawRequest{Number: n[0], Amount: 71}); !errors.Is(err, errs.ErrInsufficientFunds) {
+		t.Errorf("expected ErrInsufficientFunds, but got %v", err)
+	}
+
+	history, _ := srv.GetTransactions(ctx, param.GetTransactionsRequest{Number: n[0]})
+	if len(history.Transactions) != 2 || history.Transactions[0].Kind != entity.KindWithdrawal || history.Transactions[0].Reference != "atm" {
+		t.Errorf("expected deposit and withdrawal in history, but got %+v", history.Transactions)
+	}
+}
+
+func TestGetTransactionsPaginates(t *testing.T) {
+	srv, n := newTestDepository(t, 100, 0)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 10}); err != nil {
+			t.Fatalf("unexpected error while transferring: %s", err.Error())
+		}
+	}
+
+	req := param.GetTransactionsRequest{Number: n[0], Limit: 2}
+	seen := 0
+	for {
+		resp, err := srv.GetTransactions(ctx, req)
+		if err != nil {
+			t.Fatalf("unexpected error while listing transactions: %s", err.Error())
+		}
+		seen += len(resp.Transactions)
+		if resp.NextCursor == "" {
+			break
+		}
+		req.Cursor = resp.NextCursor
+	}
+
+	// five transfers plus the opening balance
+	if seen != 6 {
+		t.Errorf("expected 6 transactions, but got %d", seen)
+	}
+
+	resp, _ := srv.GetTransactions(ctx, param.GetTransactionsRequest{Number: n[0], Direction: "out", MinAmount: 10})
+	if len(resp.Transactions) != 5 || resp.Transactions[0].Counterparty != n[1] {
+		t.Errorf("expected 5 outgoing transactions to %d, but got %+v", n[1], resp.Transactions)
+	}
+}
+
+func TestTransferAmountIdempotency(t *testing.T) {
+	srv, n := newTestDepository(t, 100, 0)
+	ctx := context.Background()
+	req := param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 10, IdempotencyKey: "key-1"}
+
+	first, err := srv.TransferAmount(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error while transferring: %s", err.Error())
+	}
+	replayed, err := srv.TransferAmount(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error while replaying: %s", err.Error())
+	}
+	if replayed != first {
+		t.Errorf("expected replay to return %+v, but got %+v", first, replayed)
+	}
+
+	req.Amount = 20
+	if _, err := srv.TransferAmount(ctx, req); !errors.Is(err, errs.ErrIdempotencyKeyConflict) {
+		t.Errorf("expected ErrIdempotencyKeyConflict, but got %v", err)
+	}
+
+	acc, _ := srv.GetAccountByNumber(ctx, param.GetAccountByNumberRequest{Number: n[0]})
+	if acc.Balance != 90 {
+		t.Errorf("expected money to move once, but balance is %d", acc.Balance)
+	}
+}
+
+func TestIdempotencyKeysAreScopedToSender(t *testing.T) {
+	srv, n := newTestDepository(t, 100, 100, 0)
+	ctx := context.Background()
+
+	for _, from := range n[:2] {
+		req := param.TransferAmountRequest{FromAccount: from, ToAccount: n[2], Amount: 10, IdempotencyKey: "key-1"}
+		if _, err := srv.TransferAmount(ctx, req); err != nil {
+			t.Fatalf("unexpected error while transferring from %d: %s", from, err.Error())
+		}
+	}
+
+	acc, _ := srv.GetAccountByNumber(ctx, param.GetAccountByNumberRequest{Number: n[2]})
+	if acc.Balance != 20 {
+		t.Errorf("expected both transfers to go through, but balance is %d", acc.Balance)
+	}
+}
+
+func TestAbandonedIdempotencyKeyIsReclaimed(t *testing.T) {
+	srv, n := newTestDepository(t, 100, 0)
+	ctx := context.Background()
+
+	// requests that crashed after reserving their key, a moment and a long
+	// time ago
+	reserve := func(key string, age time.Duration) param.TransferAmountRequest {
+		req := param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 10, IdempotencyKey: key}
+		hash, err := requestHash(req)
+		if err != nil {
+			t.Fatalf("unexpected error while hashing request: %s", err.Error())
+		}
+		rec := &entity.IdempotencyRecord{FromAccount: n[0], Key: key, RequestHash: hash, CreatedAt: time.Now().UTC().Add(-age)}
+		if err := srv.repo.CreateIdempotencyRecord(ctx, rec); err != nil {
+			t.Fatalf("unexpected error while reserving key: %s", err.Error())
+		}
+		return req
+	}
+	fresh := reserve("key-1", time.Second)
+	stale := reserve("key-2", idempotentTransferTimeout+time.Second)
+
+	if _, err := srv.TransferAmount(ctx, fresh); !errors.Is(err, errs.ErrIdempotencyKeyInProgress) {
+		t.Errorf("expected %v, but got %v", errs.ErrIdempotencyKeyInProgress, err)
+	}
+
+	first, err := srv.TransferAmount(ctx, stale)
+	if err != nil {
+		t.Fatalf("expected the abandoned key to be reclaimed, but got %v", err)
+	}
+	replayed, err := srv.TransferAmount(ctx, stale)
+	if err != nil || replayed != first {
+		t.Errorf("expected replay to return %+v, but got %+v, %v", first, replayed, err)
+	}
+
+	acc, _ := srv.GetAccountByNumber(ctx, param.GetAccountByNumberRequest{Number: n[0]})
+	if acc.Balance != 90 {
+		t.Errorf("expected money to move once, but balance is %d", acc.Balance)
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+
+	"github.com/mohamadafzal06/depository/param"
+	"github.com/mohamadafzal06/depository/repository"
+)
+
+const (
+	checkOK      = "ok"
+	checkPending = "pending"
+)
+
+// Readiness checks that the repository is reachable and, when it has a
+// versioned schema, that every migration is applied.
+func (s *Depository) Readiness(ctx context.Context) param.ReadinessResponse {
+	resp := param.ReadinessResponse{Ready: true, Checks: map[string]string{}}
+
+	if err := s.repo.Ping(ctx); err != nil {
+		resp.Ready = false
+		resp.Checks["database"] = err.Error()
+		return resp
+	}
+	resp.Checks["database"] = checkOK
+
+	if checker, ok := s.repo.(repository.SchemaChecker); ok {
+		current, err := checker.SchemaCurrent(ctx)
+		switch {
+		case err != nil:
+			resp.Ready = false
+			resp.Checks["migrations"] = err.Error()
+		case !current:
+			resp.Ready = false
+			resp.Checks["migrations"] = checkPending
+		default:
+			resp.Checks["migrations"] = checkOK
+		}
+	}
+
+	return resp
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+const maxIdempotencyKeyLength = 255
+
+func requestHash(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// idempotentTransferTimeout is how long a reservation may stay without a
+// response before it is taken for the reservation of a request that crashed.
+// It is well beyond the time a transfer can take.
+const idempotentTransferTimeout = 5 * time.Minute
+
+// idempotentTransfer reserves the idempotency key of req before moving any
+// money, so a retried request either replays the stored response or is
+// rejected while the first one is still running.
+func (s *Depository) idempotentTransfer(ctx context.Context, req param.TransferAmountRequest) (param.TransferAmountResponse, error) {
+	if len(req.IdempotencyKey) > maxIdempotencyKeyLength {
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, errs.ErrIdempotencyKeyTooLong
+	}
+
+	hash, err := requestHash(req)
+	if err != nil {
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, fmt.Errorf("cannot hash the request: %w", err)
+	}
+
+	// postgres keeps microseconds, and the reservation is matched by its time
+	rec := &entity.IdempotencyRecord{
+		FromAccount: req.FromAccount,
+		Key:         req.IdempotencyKey,
+		RequestHash: hash,
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+	}
+	err = s.repo.CreateIdempotencyRecord(ctx, rec)
+	if errors.Is(err, errs.ErrIdempotencyKeyExists) {
+		return s.replayTransfer(ctx, req, rec)
+	}
+	if err != nil {
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, fmt.Errorf("cannot store idempotency key: %w", err)
+	}
+
+	return s.completeIdempotentTransfer(ctx, req, rec)
+}
+
+// completeIdempotentTransfer moves the money of req under the reservation
+// rec. The response is stored in the same transaction as the transfer, so a
+// reservation without a response never stands for money that has moved.
+func (s *Depository) completeIdempotentTransfer(ctx context.Context, req param.TransferAmountRequest, rec *entity.IdempotencyRecord) (param.TransferAmountResponse, error) {
+	response, err := s.transferAmount(ctx, req, rec)
+	if err != nil {
+		// nothing has been moved, so the client is free to retry with the same key
+		if derr := s.repo.DeleteIdempotencyRecord(ctx, rec); derr != nil {
+			slog.ErrorContext(ctx, "cannot release idempotency key", "idempotency_key", req.IdempotencyKey, "error", derr)
+		}
+		return response, err
+	}
+
+	return response, nil
+}
+
+func (s *Depository) replayTransfer(ctx context.Context, req param.TransferAmountRequest, rec *entity.IdempotencyRecord) (param.TransferAmountResponse, error) {
+	stored, err := s.repo.GetIdempotencyRecord(ctx, rec.FromAccount, rec.Key)
+	if err != nil {
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, fmt.Errorf("cannot get idempotency key: %w", err)
+	}
+
+	if stored.RequestHash != rec.RequestHash {
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, errs.ErrIdempotencyKeyConflict
+	}
+	if !stored.Completed() {
+		staleBefore := rec.CreatedAt.Add(-idempotentTransferTimeout)
+		if stored.CreatedAt.Before(staleBefore) {
+			err := s.repo.ReclaimIdempotencyRecord(ctx, rec, staleBefore)
+			if err == nil {
+				slog.WarnContext(ctx, "reclaimed abandoned idempotency key", "idempotency_key", rec.Key, "reserved_at", stored.CreatedAt)
+				return s.completeIdempotentTransfer(ctx, req, rec)
+			}
+			if !errors.Is(err, errs.ErrIdempotencyKeyInProgress) {
+				return param.TransferAmountResponse{Status: param.Unsuccessful}, err
+			}
+		}
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, errs.ErrIdempotencyKeyInProgress
+	}
+
+	var response param.TransferAmountResponse
+	if err := json.Unmarshal(stored.Response, &response); err != nil {
+		return param.TransferAmountResponse{Status: param.Unsuccessful}, fmt.Errorf("cannot decode stored response: %w", err)
+	}
+
+	return response, nil
+}
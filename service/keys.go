@@ -0,0 +1,231 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// KeyConfig describes one key of the key set. A key with only a public key
+// file can no longer sign and is kept so tokens it signed keep verifying
+// during rotation. HMAC keys use Secret, or the secret read from SecretFile,
+// instead of PEM files.
+type KeyConfig struct {
+	ID             string
+	Algorithm      string
+	PrivateKeyFile string
+	PublicKeyFile  string
+	Secret         string
+	SecretFile     string
+}
+
+// SigningKey is a key of the key set together with its signing method.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func (k *SigningKey) CanSign() bool {
+	return k.signKey != nil
+}
+
+// LoadSigningKey loads the key described by cfg from its PEM files.
+func LoadSigningKey(cfg KeyConfig) (*SigningKey, error) {
+	key := &SigningKey{ID: cfg.ID, Method: jwt.GetSigningMethod(cfg.Algorithm)}
+	if key.Method == nil {
+		return nil, fmt.Errorf("key %q: unsupported signing algorithm %q", cfg.ID, cfg.Algorithm)
+	}
+
+	var err error
+	switch key.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if cfg.Secret == "" && cfg.SecretFile != "" {
+			b, err := os.ReadFile(cfg.SecretFile)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: cannot read secret: %w", cfg.ID, err)
+			}
+			// editors add a trailing newline, which is not part of the secret
+			cfg.Secret = strings.TrimRight(string(b), "\r\n")
+		}
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("key %q: empty secret", cfg.ID)
+		}
+		key.signKey = []byte(cfg.Secret)
+		key.verifyKey = key.signKey
+	case *jwt.SigningMethodRSA:
+		err = loadKeyPair(key, cfg, parsePEM(jwt.ParseRSAPrivateKeyFromPEM), parsePEM(jwt.ParseRSAPublicKeyFromPEM))
+	case *jwt.SigningMethodECDSA:
+		err = loadKeyPair(key, cfg, parsePEM(jwt.ParseECPrivateKeyFromPEM), parsePEM(jwt.ParseECPublicKeyFromPEM))
+		if err == nil && key.Method == jwt.SigningMethodES256 && key.verifyKey.(*ecdsa.PublicKey).Curve != elliptic.P256() {
+			err = fmt.Errorf("key %q: ES256 requires a P-256 key", cfg.ID)
+		}
+	case *jwt.SigningMethodEd25519:
+		err = loadKeyPair(key, cfg, parsePEM(jwt.ParseEdPrivateKeyFromPEM), parsePEM(jwt.ParseEdPublicKeyFromPEM))
+	default:
+		err = fmt.Errorf("key %q: unsupported signing algorithm %q", cfg.ID, cfg.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func parsePEM[T any](parse func([]byte) (T, error)) func([]byte) (interface{}, error) {
+	return func(b []byte) (interface{}, error) {
+		return parse(b)
+	}
+}
+
+// loadKeyPair reads the private and/or public key of cfg. The public key is
+// derived from the private one when no public key file is given.
+func loadKeyPair(key *SigningKey, cfg KeyConfig, parsePrivate, parsePublic func([]byte) (interface{}, error)) error {
+	if cfg.PrivateKeyFile == "" && cfg.PublicKeyFile == "" {
+		return fmt.Errorf("key %q: no key file", cfg.ID)
+	}
+
+	if cfg.PrivateKeyFile != "" {
+		b, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return fmt.Errorf("key %q: cannot read private key: %w", cfg.ID, err)
+		}
+		if key.signKey, err = parsePrivate(b); err != nil {
+			return fmt.Errorf("key %q: cannot parse private key: %w", cfg.ID, err)
+		}
+
+		signer, ok := key.signKey.(crypto.Signer)
+		if !ok {
+			return fmt.Errorf("key %q: private key cannot sign", cfg.ID)
+		}
+		key.verifyKey = signer.Public()
+	}
+
+	if cfg.PublicKeyFile != "" {
+		b, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return fmt.Errorf("key %q: cannot read public key: %w", cfg.ID, err)
+		}
+		if key.verifyKey, err = parsePublic(b); err != nil {
+			return fmt.Errorf("key %q: cannot parse public key: %w", cfg.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// KeySet signs new tokens with its active key and verifies tokens signed by
+// any of its keys, selected by the kid header.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+func NewKeySet(activeID string, keys ...*SigningKey) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+	for _, k := range keys {
+		if _, ok := ks.keys[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+	}
+
+	ks.active = ks.keys[activeID]
+	if ks.active == nil {
+		return nil, fmt.Errorf("active key %q is not in the key set", activeID)
+	}
+	if !ks.active.CanSign() {
+		return nil, fmt.Errorf("active key %q has no private key", activeID)
+	}
+
+	return ks, nil
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.ID
+
+	return token.SignedString(ks.active.signKey)
+}
+
+// keyFunc finds the verification key of a token. Tokens without a kid were
+// signed before key rotation existed and are checked against the active key.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	key := ks.active
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = ks.keys[kid]; !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.verifyKey, nil
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set. Symmetric keys are never
+// published.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, k := range ks.keys {
+		jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+
+		switch pub := k.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = b64(pub.N.Bytes())
+			jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = b64(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+
+	return set
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mohamadafzal06/depository/param"
+	"github.com/mohamadafzal06/depository/repository/memory"
+)
+
+// writeKeyPair stores key as PKCS#8 and its public key as PKIX PEM files.
+func writeKeyPair(t *testing.T, name string, key crypto.Signer) (string, string) {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("cannot marshal private key: %s", err.Error())
+	}
+	pubDer, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("cannot marshal public key: %s", err.Error())
+	}
+
+	dir := t.TempDir()
+	priv := filepath.Join(dir, name+".pem")
+	pub := filepath.Join(dir, name+".pub.pem")
+	os.WriteFile(priv, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	os.WriteFile(pub, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDer}), 0o600)
+
+	return priv, pub
+}
+
+func TestAsymmetricKeysSignAndVerify(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		alg string
+		key crypto.Signer
+		kty string
+	}{
+		{"RS256", rsaKey, "RSA"},
+		{"ES256", ecKey, "EC"},
+		{"EdDSA", edKey, "OKP"},
+	}
+
+	for _, tt := range tests {
+		priv, _ := writeKeyPair(t, tt.alg, tt.key)
+		auth, err := NewAuth(AuthConfig{
+			Keys:                 []KeyConfig{{ID: tt.alg, Algorithm: tt.alg, PrivateKeyFile: priv}},
+			ActiveKeyID:          tt.alg,
+			AccessExpirationTime: time.Minute,
+			AccessSubject:        "at",
+		}, memory.New())
+		if err != nil {
+			t.Fatalf("unexpected error while creating auth with %s: %s", tt.alg, err.Error())
+		}
+
+		resp, _ := auth.CreateAccessToken(param.LoginRequest{Number: 12345678})
+		claims, err := auth.ParseToken(resp.TokenString)
+		if err != nil || claims.Number != 12345678 {
+			t.Errorf("expected %s token to verify, but got %v", tt.alg, err)
+		}
+
+		jwks := auth.JWKS()
+		if len(jwks.Keys) != 1 || jwks.Keys[0].Kty != tt.kty || jwks.Keys[0].Kid != tt.alg {
+			t.Errorf("expected one %s key in JWKS, but got %+v", tt.kty, jwks.Keys)
+		}
+	}
+}
+
+func TestHMACKeyReadsSecretFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "k1.secret")
+	if err := os.WriteFile(path, []byte("shared-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := LoadSigningKey(KeyConfig{ID: "k1", Algorithm: "HS256", SecretFile: path})
+	if err != nil {
+		t.Fatalf("unexpected error while loading key: %s", err.Error())
+	}
+	if secret := string(key.signKey.([]byte)); secret != "shared-secret" {
+		t.Errorf("expected the secret without its trailing newline, but got %q", secret)
+	}
+
+	if _, err := LoadSigningKey(KeyConfig{ID: "k2", Algorithm: "HS256", SecretFile: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Errorf("expected an error for a missing secret file")
+	}
+}
+
+func TestRotatedKeyKeepsVerifying(t *testing.T) {
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	oldPriv, oldPub := writeKeyPair(t, "old", oldKey)
+	newPriv, _ := writeKeyPair(t, "new", newKey)
+
+	cfg := AuthConfig{
+		Keys:                 []KeyConfig{{ID: "old", Algorithm: "ES256", PrivateKeyFile: oldPriv}},
+		ActiveKeyID:          "old",
+		AccessExpirationTime: time.Minute,
+		AccessSubject:        "at",
+	}
+	before, _ := NewAuth(cfg, memory.New())
+	token, _ := before.CreateAccessToken(param.LoginRequest{Number: 12345678})
+
+	cfg.Keys = []KeyConfig{
+		{ID: "new", Algorithm: "ES256", PrivateKeyFile: newPriv},
+		{ID: "old", Algorithm: "ES256", PublicKeyFile: oldPub},
+	}
+	cfg.ActiveKeyID = "new"
+	after, err := NewAuth(cfg, memory.New())
+	if err != nil {
+		t.Fatalf("unexpected error while rotating keys: %s", err.Error())
+	}
+
+	if _, err := after.ParseToken(token.TokenString); err != nil {
+		t.Errorf("expected token of the retired key to verify, but got %v", err)
+	}
+
+	cfg.ActiveKeyID = "old"
+	if _, err := NewAuth(cfg, memory.New()); err == nil {
+		t.Errorf("expected a verify-only key to be rejected as active key")
+	}
+}
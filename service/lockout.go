@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+// LockoutPolicy locks an account after MaxFailures consecutive failed logins
+// within Window. The first lock lasts BaseLockout and every further one twice
+// as long as the one before, up to MaxLockout. A zero MaxFailures disables
+// locking.
+type LockoutPolicy struct {
+	MaxFailures int
+	Window      time.Duration
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	MaxFailures: 5,
+	Window:      15 * time.Minute,
+	BaseLockout: time.Minute,
+	MaxLockout:  24 * time.Hour,
+}
+
+func (s *Depository) SetLockoutPolicy(p LockoutPolicy) {
+	s.lockout = p
+}
+
+// lockDuration returns how long the lockouts-th lock of an account lasts.
+func (p LockoutPolicy) lockDuration(lockouts int) time.Duration {
+	d := p.BaseLockout
+	for i := 1; i < lockouts && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+	if p.MaxLockout > 0 && d > p.MaxLockout {
+		d = p.MaxLockout
+	}
+	return d
+}
+
+// loginLockout returns the failed logins on record for number, or an empty
+// record when there are none.
+func (s *Depository) loginLockout(ctx context.Context, number int64) (*entity.LoginLockout, error) {
+	l, err := s.repo.GetLoginLockout(ctx, number)
+	if errors.Is(err, errs.ErrLockoutNotFound) {
+		return &entity.LoginLockout{Number: number}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get login lockout: %w", err)
+	}
+
+	return l, nil
+}
+
+// recordLoginFailure counts a failed login of number and locks the account
+// once it reaches the limit. The repository counts atomically, so failures
+// sent in parallel cannot slip past the limit.
+func (s *Depository) recordLoginFailure(ctx context.Context, number int64, now time.Time) error {
+	if s.lockout.MaxFailures <= 0 {
+		return nil
+	}
+
+	l, err := s.repo.AddLoginFailure(ctx, number, now, s.lockout.Window)
+	if err != nil {
+		return err
+	}
+	if l.Failures < s.lockout.MaxFailures {
+		return nil
+	}
+
+	lockedUntil := now.Add(s.lockout.lockDuration(l.Lockouts + 1))
+	locked, err := s.repo.LockLogin(ctx, number, s.lockout.MaxFailures, lockedUntil)
+	if err != nil {
+		return err
+	}
+	if locked {
+		slog.WarnContext(ctx, "account locked after failed logins", "number", number, "locked_until", lockedUntil)
+	}
+
+	return nil
+}
+
+// UnlockAccount lifts the lock of an account and forgets its failed logins.
+func (s *Depository) UnlockAccount(ctx context.Context, req param.UnlockAccountRequest) error {
+	if _, err := s.repo.GetAccountByNumber(ctx, req.Number); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteLoginLockout(ctx, req.Number); err != nil {
+		return fmt.Errorf("cannot unlock account: %w", err)
+	}
+	slog.InfoContext(ctx, "account unlocked", "number", req.Number)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+func TestCheckPassLocksAccountAfterFailures(t *testing.T) {
+	srv, n := newTestDepository(t, 0)
+	srv.SetLockoutPolicy(LockoutPolicy{MaxFailures: 3, Window: time.Minute, BaseLockout: time.Minute, MaxLockout: time.Hour})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := srv.CheckPass(ctx, param.LoginRequest{Number: n[0], Password: "wrong"}); !errors.Is(err, errs.ErrInvalidCredentials) {
+			t.Fatalf("expected ErrInvalidCredentials, but got %v", err)
+		}
+	}
+
+	if _, err := srv.CheckPass(ctx, param.LoginRequest{Number: n[0], Password: "mypassword"}); !errors.Is(err, errs.ErrAccountLocked) {
+		t.Fatalf("expected ErrAccountLocked even with the right password, but got %v", err)
+	}
+
+	if err := srv.UnlockAccount(ctx, param.UnlockAccountRequest{Number: n[0]}); err != nil {
+		t.Fatalf("unexpected error while unlocking: %s", err.Error())
+	}
+	if resp, err := srv.CheckPass(ctx, param.LoginRequest{Number: n[0], Password: "mypassword"}); err != nil || !resp.Truly {
+		t.Errorf("expected login to succeed after unlocking, but got %v", err)
+	}
+}
+
+func TestConcurrentLoginFailuresAllCount(t *testing.T) {
+	srv, n := newTestDepository(t, 0)
+	srv.SetLockoutPolicy(LockoutPolicy{MaxFailures: 100, Window: time.Minute, BaseLockout: time.Minute, MaxLockout: time.Hour})
+	ctx := context.Background()
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srv.CheckPass(ctx, param.LoginRequest{Number: n[0], Password: "wrong"})
+		}()
+	}
+	wg.Wait()
+
+	l, err := srv.repo.GetLoginLockout(ctx, n[0])
+	if err != nil {
+		t.Fatalf("unexpected error while getting lockout: %s", err.Error())
+	}
+	if l.Failures != attempts {
+		t.Errorf("expected %d failures, but got %d", attempts, l.Failures)
+	}
+}
+
+func TestLockDurationGrows(t *testing.T) {
+	p := LockoutPolicy{BaseLockout: time.Minute, MaxLockout: 5 * time.Minute}
+
+	for lockouts, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 3: 4 * time.Minute, 4: 5 * time.Minute, 10: 5 * time.Minute} {
+		if got := p.lockDuration(lockouts); got != want {
+			t.Errorf("expected lock %d to last %s, but got %s", lockouts, want, got)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+// MFAPolicy configures TOTP two-factor authentication. Transfers of more than
+// TransferThreshold, in minor units of the sending account's currency, out of
+// an account with two-factor authentication need a fresh TOTP code; zero
+// never asks for one.
+type MFAPolicy struct {
+	Issuer            string
+	TransferThreshold int64
+}
+
+var DefaultMFAPolicy = MFAPolicy{Issuer: "depository"}
+
+const recoveryCodeCount = 10
+
+func (s *Depository) SetMFAPolicy(p MFAPolicy) {
+	s.mfa = p
+}
+
+// EnrollMFA starts enrolling an account in two-factor authentication. It
+// stays disabled until ConfirmMFA is called with a code from the secret.
+// Enrolling again before confirming replaces the secret and recovery codes.
+func (s *Depository) EnrollMFA(ctx context.Context, req param.EnrollMFARequest) (resp param.EnrollMFAResponse, err error) {
+	ctx, end := startSpan(ctx, "Depository.EnrollMFA")
+	defer func() { end(err) }()
+
+	existing, err := s.repo.GetMFA(ctx, req.Number)
+	if err == nil && existing.Enabled {
+		return param.EnrollMFAResponse{}, errs.ErrMFAAlreadyEnabled
+	}
+	if err != nil && !errors.Is(err, errs.ErrMFANotEnrolled) {
+		return param.EnrollMFAResponse{}, fmt.Errorf("cannot get mfa: %w", err)
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return param.EnrollMFAResponse{}, fmt.Errorf("cannot create totp secret: %w", err)
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return param.EnrollMFAResponse{}, fmt.Errorf("cannot create recovery codes: %w", err)
+	}
+
+	err = s.repo.SaveMFA(ctx, &entity.MFA{
+		Number:        req.Number,
+		Secret:        secret,
+		RecoveryCodes: hashes,
+		CreatedAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		return param.EnrollMFAResponse{}, fmt.Errorf("cannot save mfa: %w", err)
+	}
+
+	return param.EnrollMFAResponse{
+		Secret:          secret,
+		ProvisioningURI: provisioningURI(s.mfa.Issuer, req.Number, secret),
+		RecoveryCodes:   codes,
+	}, nil
+}
+
+// ConfirmMFA enables two-factor authentication once the holder proves the
+// authenticator app generates the right codes.
+func (s *Depository) ConfirmMFA(ctx context.Context, req param.MFACodeRequest) (err error) {
+	ctx, end := startSpan(ctx, "Depository.ConfirmMFA")
+	defer func() { end(err) }()
+
+	mfa, err := s.repo.GetMFA(ctx, req.Number)
+	if err != nil {
+		return err
+	}
+	if mfa.Enabled {
+		return errs.ErrMFAAlreadyEnabled
+	}
+
+	step, ok := verifyTOTP(mfa.Secret, strings.TrimSpace(req.Code), time.Now().UTC())
+	if !ok {
+		return errs.ErrInvalidMFACode
+	}
+	mfa.Enabled = true
+	mfa.LastUsedStep = step
+	if err := s.repo.SaveMFA(ctx, mfa); err != nil {
+		return fmt.Errorf("cannot save mfa: %w", err)
+	}
+	slog.InfoContext(ctx, "two-factor authentication enabled", "number", req.Number)
+
+	return nil
+}
+
+// DisableMFA removes the second factor of an account. It needs the password
+// and a TOTP or recovery code.
+func (s *Depository) DisableMFA(ctx context.Context, req param.DisableMFARequest) (err error) {
+	ctx, end := startSpan(ctx, "Depository.DisableMFA")
+	defer func() { end(err) }()
+
+	if _, err := s.CheckPass(ctx, param.LoginRequest{Number: req.Number, Password: req.Password}); err != nil {
+		return err
+	}
+	if err := s.verifyMFA(ctx, req.Number, req.Code, true); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteMFA(ctx, req.Number); err != nil {
+		return fmt.Errorf("cannot delete mfa: %w", err)
+	}
+	slog.InfoContext(ctx, "two-factor authentication disabled", "number", req.Number)
+
+	return nil
+}
+
+// MFAEnabled reports whether logins to an account need a second factor.
+func (s *Depository) MFAEnabled(ctx context.Context, number int64) (bool, error) {
+	mfa, err := s.repo.GetMFA(ctx, number)
+	if errors.Is(err, errs.ErrMFANotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot get mfa: %w", err)
+	}
+
+	return mfa.Enabled, nil
+}
+
+// VerifyLoginMFA checks the second step of a login, which accepts a TOTP or a
+// recovery code. Wrong codes count as failed logins.
+func (s *Depository) VerifyLoginMFA(ctx context.Context, req param.MFACodeRequest) (err error) {
+	ctx, end := startSpan(ctx, "Depository.VerifyLoginMFA")
+	defer func() { end(err) }()
+
+	return s.verifyMFAWithLockout(ctx, req.Number, req.Code, true)
+}
+
+// verifyMFAWithLockout verifies code like verifyMFA, but refuses while the
+// account is locked and counts a wrong code as a failed login, so codes
+// cannot be guessed at the login or at a transfer.
+func (s *Depository) verifyMFAWithLockout(ctx context.Context, number int64, code string, recovery bool) error {
+	now := time.Now().UTC()
+	lockout, err := s.loginLockout(ctx, number)
+	if err != nil {
+		return err
+	}
+	if lockout.Locked(now) {
+		return fmt.Errorf("%w until %s", errs.ErrAccountLocked, lockout.LockedUntil.Format(time.RFC3339))
+	}
+
+	err = s.verifyMFA(ctx, number, code, recovery)
+	if errors.Is(err, errs.ErrInvalidMFACode) {
+		if lerr := s.recordLoginFailure(ctx, number, now); lerr != nil {
+			slog.ErrorContext(ctx, "cannot record failed login", "number", number, "error", lerr)
+		}
+	}
+
+	return err
+}
+
+// checkTransferMFA asks for a fresh TOTP code when an account with two-factor
+// authentication sends more than the threshold. Recovery codes only unlock
+// logins.
+func (s *Depository) checkTransferMFA(ctx context.Context, number, amount int64, code string) error {
+	if s.mfa.TransferThreshold <= 0 || amount <= s.mfa.TransferThreshold {
+		return nil
+	}
+	enabled, err := s.MFAEnabled(ctx, number)
+	if err != nil || !enabled {
+		return err
+	}
+	if code == "" {
+		return errs.ErrMFARequired
+	}
+
+	return s.verifyMFAWithLockout(ctx, number, code, false)
+}
+
+// verifyMFA accepts a TOTP code that has not been used yet and, with
+// recovery set, an unused recovery code.
+func (s *Depository) verifyMFA(ctx context.Context, number int64, code string, recovery bool) error {
+	mfa, err := s.repo.GetMFA(ctx, number)
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled {
+		return errs.ErrMFANotEnrolled
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := verifyTOTP(mfa.Secret, code, time.Now().UTC()); ok {
+		return s.repo.UseMFAStep(ctx, number, step)
+	}
+	if recovery && code != "" {
+		if err := s.repo.UseMFARecoveryCode(ctx, number, hashToken(normalizeRecoveryCode(code))); err != nil {
+			return err
+		}
+		slog.WarnContext(ctx, "recovery code used", "number", number)
+		return nil
+	}
+
+	return errs.ErrInvalidMFACode
+}
+
+// newRecoveryCodes returns recovery codes to show to the holder once, and the
+// hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
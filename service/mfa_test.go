@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+
+	// the 8 digit values of RFC 6238, appendix B, cut to 6 digits
+	for unix, want := range map[int64]string{59: "287082", 1111111109: "081804", 2000000000: "279037"} {
+		if got := totpCode(key, totpStep(time.Unix(unix, 0))); got != want {
+			t.Errorf("expected code %s at %d, but got %s", want, unix, got)
+		}
+	}
+}
+
+func TestMFAGuardsLoginAndLargeTransfers(t *testing.T) {
+	srv, n := newTestDepository(t, 1000, 0)
+	srv.SetMFAPolicy(MFAPolicy{Issuer: "depository", TransferThreshold: 100})
+	ctx := context.Background()
+
+	enrollment, err := srv.EnrollMFA(ctx, param.EnrollMFARequest{Number: n[0]})
+	if err != nil {
+		t.Fatalf("unexpected error while enrolling: %s", err.Error())
+	}
+	key, err := totpEncoding.DecodeString(enrollment.Secret)
+	if err != nil {
+		t.Fatalf("unexpected error while decoding secret: %s", err.Error())
+	}
+	code := func(offset int64) string {
+		return totpCode(key, totpStep(time.Now())+offset)
+	}
+
+	if err := srv.ConfirmMFA(ctx, param.MFACodeRequest{Number: n[0], Code: code(0)}); err != nil {
+		t.Fatalf("unexpected error while confirming: %s", err.Error())
+	}
+	if err := srv.VerifyLoginMFA(ctx, param.MFACodeRequest{Number: n[0], Code: code(0)}); !errors.Is(err, errs.ErrInvalidMFACode) {
+		t.Errorf("expected a used code to be rejected, but got %v", err)
+	}
+
+	recovery := enrollment.RecoveryCodes[0]
+	if err := srv.VerifyLoginMFA(ctx, param.MFACodeRequest{Number: n[0], Code: recovery}); err != nil {
+		t.Errorf("unexpected error while logging in with a recovery code: %s", err.Error())
+	}
+	if err := srv.VerifyLoginMFA(ctx, param.MFACodeRequest{Number: n[0], Code: recovery}); !errors.Is(err, errs.ErrInvalidMFACode) {
+		t.Errorf("expected a used recovery code to be rejected, but got %v", err)
+	}
+
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 100}); err != nil {
+		t.Errorf("unexpected error while transferring up to the threshold: %s", err.Error())
+	}
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 101}); !errors.Is(err, errs.ErrMFARequired) {
+		t.Errorf("expected ErrMFARequired above the threshold, but got %v", err)
+	}
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 101, TOTPCode: code(1)}); err != nil {
+		t.Errorf("unexpected error while transferring with a fresh code: %s", err.Error())
+	}
+}
+
+func TestWrongTransferCodesLockAccount(t *testing.T) {
+	srv, n := newTestDepository(t, 1000, 0)
+	srv.SetMFAPolicy(MFAPolicy{Issuer: "depository", TransferThreshold: 100})
+	srv.SetLockoutPolicy(LockoutPolicy{MaxFailures: 3, Window: time.Minute, BaseLockout: time.Minute, MaxLockout: time.Hour})
+	ctx := context.Background()
+
+	enrollment, err := srv.EnrollMFA(ctx, param.EnrollMFARequest{Number: n[0]})
+	if err != nil {
+		t.Fatalf("unexpected error while enrolling: %s", err.Error())
+	}
+	key, err := totpEncoding.DecodeString(enrollment.Secret)
+	if err != nil {
+		t.Fatalf("unexpected error while decoding secret: %s", err.Error())
+	}
+	if err := srv.ConfirmMFA(ctx, param.MFACodeRequest{Number: n[0], Code: totpCode(key, totpStep(time.Now()))}); err != nil {
+		t.Fatalf("unexpected error while confirming: %s", err.Error())
+	}
+	wrong := totpCode(key, totpStep(time.Now())+10)
+
+	for i := 0; i < 3; i++ {
+		_, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 101, TOTPCode: wrong})
+		if !errors.Is(err, errs.ErrInvalidMFACode) {
+			t.Fatalf("expected ErrInvalidMFACode, but got %v", err)
+		}
+	}
+	_, err = srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 101, TOTPCode: totpCode(key, totpStep(time.Now())+1)})
+	if !errors.Is(err, errs.ErrAccountLocked) {
+		t.Errorf("expected ErrAccountLocked even with the right code, but got %v", err)
+	}
+}
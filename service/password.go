@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/notify"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+// maxPasswordLength is the most bcrypt hashes; longer passwords are rejected
+// rather than silently truncated.
+const maxPasswordLength = 72
+
+// PasswordPolicy is checked whenever a password is set.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8}
+
+const defaultResetTokenTTL = 30 * time.Minute
+
+func (s *Depository) SetPasswordPolicy(p PasswordPolicy) {
+	s.passwords = p
+}
+
+// SetPasswordReset sets how reset tokens are delivered and how long they can
+// be used. Password resets are disabled while n is nil.
+func (s *Depository) SetPasswordReset(n notify.Notifier, ttl time.Duration) {
+	s.notifier = n
+	s.resetTTL = ttl
+}
+
+// Check returns errs.ErrWeakPassword naming the first requirement password
+// does not meet.
+func (p PasswordPolicy) Check(password string) error {
+	if len(password) < p.MinLength {
+		return fmt.Errorf("%w: it must be at least %d characters long", errs.ErrWeakPassword, p.MinLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("%w: it must be at most %d bytes long", errs.ErrWeakPassword, maxPasswordLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	switch {
+	case p.RequireUpper && !upper:
+		return fmt.Errorf("%w: it must contain an upper case letter", errs.ErrWeakPassword)
+	case p.RequireLower && !lower:
+		return fmt.Errorf("%w: it must contain a lower case letter", errs.ErrWeakPassword)
+	case p.RequireDigit && !digit:
+		return fmt.Errorf("%w: it must contain a digit", errs.ErrWeakPassword)
+	case p.RequireSymbol && !symbol:
+		return fmt.Errorf("%w: it must contain a symbol", errs.ErrWeakPassword)
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the password of an account after checking the
+// current one. Wrong current passwords count as failed logins.
+func (s *Depository) ChangePassword(ctx context.Context, req param.ChangePasswordRequest) (err error) {
+	ctx, end := startSpan(ctx, "Depository.ChangePassword")
+	defer func() { end(err) }()
+
+	if err := s.passwords.Check(req.NewPassword); err != nil {
+		return err
+	}
+	if _, err := s.CheckPass(ctx, param.LoginRequest{Number: req.Number, Password: req.CurrentPassword}); err != nil {
+		return err
+	}
+
+	return s.setPassword(ctx, req.Number, req.NewPassword)
+}
+
+// RequestPasswordReset sends a single-use reset token to the holder of an
+// account. It succeeds for unknown accounts as well, so callers cannot tell
+// which accounts exist.
+func (s *Depository) RequestPasswordReset(ctx context.Context, req param.RequestPasswordResetRequest) (err error) {
+	ctx, end := startSpan(ctx, "Depository.RequestPasswordReset")
+	defer func() { end(err) }()
+
+	if s.notifier == nil {
+		return errs.ErrResetDisabled
+	}
+
+	if _, err := s.repo.GetAccountByNumber(ctx, req.Number); err != nil {
+		slog.InfoContext(ctx, "password reset requested for unknown account", "number", req.Number)
+		return nil
+	}
+
+	token, err := newResetToken()
+	if err != nil {
+		return fmt.Errorf("cannot create reset token: %w", err)
+	}
+	now := time.Now().UTC()
+	err = s.repo.CreatePasswordResetToken(ctx, &entity.PasswordResetToken{
+		ID:        entity.NewID(),
+		Number:    req.Number,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(s.resetTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot store reset token: %w", err)
+	}
+
+	err = s.notifier.Notify(ctx, notify.Message{
+		To:      req.Number,
+		Subject: "Password reset",
+		Body:    fmt.Sprintf("Use this token within %s to reset your password: %s", s.resetTTL, token),
+		SentAt:  now,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot send reset token: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token, which can be used
+// once.
+func (s *Depository) ResetPassword(ctx context.Context, req param.ResetPasswordRequest) (err error) {
+	ctx, end := startSpan(ctx, "Depository.ResetPassword")
+	defer func() { end(err) }()
+
+	if err := s.passwords.Check(req.NewPassword); err != nil {
+		return err
+	}
+
+	tok, err := s.repo.GetPasswordResetToken(ctx, hashToken(req.Token))
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if tok.Used() || tok.Expired(now) {
+		return errs.ErrInvalidToken
+	}
+	// marking the token used first makes concurrent resets with it fail
+	if err := s.repo.UsePasswordResetToken(ctx, tok.ID, now); err != nil {
+		return err
+	}
+
+	return s.setPassword(ctx, tok.Number, req.NewPassword)
+}
+
+// setPassword stores a new password and revokes the refresh tokens of the
+// account. The new token version ends its access tokens right away.
+func (s *Depository) setPassword(ctx context.Context, number int64, password string) error {
+	encPass, err := entity.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("cannot hash password: %w", err)
+	}
+	if err := s.repo.UpdatePassword(ctx, number, encPass); err != nil {
+		return fmt.Errorf("cannot change password: %w", err)
+	}
+	if err := s.repo.RevokeRefreshTokens(ctx, number, time.Now().UTC()); err != nil {
+		return fmt.Errorf("cannot revoke sessions: %w", err)
+	}
+	slog.InfoContext(ctx, "password changed", "number", number)
+
+	return nil
+}
+
+func newResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/notify"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+type recordingNotifier struct {
+	messages []notify.Message
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	p := PasswordPolicy{MinLength: 8, RequireUpper: true, RequireDigit: true}
+
+	for password, ok := range map[string]bool{
+		"Short1":                  false,
+		"longenough1":             false,
+		"LongEnough":              false,
+		"LongEnough1":             true,
+		strings.Repeat("Aa1", 25): false,
+	} {
+		if err := p.Check(password); (err == nil) != ok {
+			t.Errorf("expected %q to pass: %t, but got %v", password, ok, err)
+		}
+	}
+}
+
+func TestResetPasswordUsesTokenOnceAndRevokesSessions(t *testing.T) {
+	srv, n := newTestDepository(t, 0)
+	notifier := &recordingNotifier{}
+	srv.SetPasswordReset(notifier, time.Minute)
+	ctx := context.Background()
+
+	session := &entity.RefreshToken{ID: "session", FamilyID: "family", Number: n[0], TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := srv.repo.CreateRefreshToken(ctx, session); err != nil {
+		t.Fatalf("unexpected error while creating session: %s", err.Error())
+	}
+
+	if err := srv.RequestPasswordReset(ctx, param.RequestPasswordResetRequest{Number: n[0]}); err != nil {
+		t.Fatalf("unexpected error while requesting reset: %s", err.Error())
+	}
+	if len(notifier.messages) != 1 || notifier.messages[0].To != n[0] {
+		t.Fatalf("expected one message to the account holder, but got %+v", notifier.messages)
+	}
+	body := notifier.messages[0].Body
+	token := body[strings.LastIndex(body, " ")+1:]
+
+	if err := srv.ResetPassword(ctx, param.ResetPasswordRequest{Token: token, NewPassword: "short"}); !errors.Is(err, errs.ErrWeakPassword) {
+		t.Fatalf("expected ErrWeakPassword, but got %v", err)
+	}
+	if err := srv.ResetPassword(ctx, param.ResetPasswordRequest{Token: token, NewPassword: "newpassword"}); err != nil {
+		t.Fatalf("unexpected error while resetting password: %s", err.Error())
+	}
+	if err := srv.ResetPassword(ctx, param.ResetPasswordRequest{Token: token, NewPassword: "otherpassword"}); !errors.Is(err, errs.ErrInvalidToken) {
+		t.Errorf("expected a used token to be rejected, but got %v", err)
+	}
+
+	if resp, err := srv.CheckPass(ctx, param.LoginRequest{Number: n[0], Password: "newpassword"}); err != nil || !resp.Truly {
+		t.Errorf("expected the new password to work, but got %v", err)
+	}
+	if tok, err := srv.repo.GetRefreshToken(ctx, "hash"); err != nil || !tok.Revoked() {
+		t.Errorf("expected the session to be revoked, but got %+v, %v", tok, err)
+	}
+}
+
+func TestPasswordResetDisabledWithoutNotifier(t *testing.T) {
+	srv, n := newTestDepository(t, 0)
+
+	err := srv.RequestPasswordReset(context.Background(), param.RequestPasswordResetRequest{Number: n[0]})
+	if !errors.Is(err, errs.ErrResetDisabled) {
+		t.Errorf("expected %v, but got %v", errs.ErrResetDisabled, err)
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+// SetAccountRole changes the role of an account. Tokens already issued keep
+// the old role until they are refreshed.
+func (s *Depository) SetAccountRole(ctx context.Context, req param.SetAccountRoleRequest) (err error) {
+	ctx, end := startSpan(ctx, "Depository.SetAccountRole")
+	defer func() { end(err) }()
+
+	if !entity.ValidRole(req.Role) {
+		return fmt.Errorf("%w: %q", errs.ErrInvalidRole, req.Role)
+	}
+	if err := s.repo.UpdateAccountRole(ctx, req.Number, req.Role); err != nil {
+		return fmt.Errorf("cannot set role of account: %w", err)
+	}
+	slog.InfoContext(ctx, "account role changed", "number", req.Number, "role", req.Role)
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+// TransferCheck is what a TransferRule sees of a transfer about to be made.
+type TransferCheck struct {
+	From     int64
+	To       int64
+	Amount   int64
+	Currency string
+	// Limits are the effective limits of the sending account.
+	Limits entity.TransferLimits
+	Now    time.Time
+
+	stats func(since time.Time) (entity.TransferStats, error)
+}
+
+// Outgoing returns the transfers the sending account made since the given
+// time, not counting the one being checked.
+func (c TransferCheck) Outgoing(since time.Time) (entity.TransferStats, error) {
+	return c.stats(since)
+}
+
+// TransferRule decides whether a transfer may be made. Check returns an
+// *errs.LimitError naming the rule when it may not.
+type TransferRule interface {
+	Name() string
+	Check(ctx context.Context, c TransferCheck) error
+}
+
+// RulesEngine runs transfer rules in order and stops at the first one that
+// rejects a transfer.
+type RulesEngine struct {
+	rules []TransferRule
+}
+
+func NewRulesEngine(rules ...TransferRule) *RulesEngine {
+	return &RulesEngine{rules: rules}
+}
+
+func (e *RulesEngine) Evaluate(ctx context.Context, c TransferCheck) error {
+	for _, r := range e.rules {
+		if err := r.Check(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultTransferRules enforce the limits of entity.TransferLimits: the
+// largest single transfer, the outgoing total of the calendar day and month
+// (UTC) and the number of transfers in the last hour.
+func DefaultTransferRules() []TransferRule {
+	return []TransferRule{
+		maxSingleTransferRule{},
+		outgoingTotalRule{name: "daily_outgoing", limit: func(l entity.TransferLimits) int64 { return l.DailyOutgoing }, since: startOfDay},
+		outgoingTotalRule{name: "monthly_outgoing", limit: func(l entity.TransferLimits) int64 { return l.MonthlyOutgoing }, since: startOfMonth},
+		hourlyVelocityRule{},
+	}
+}
+
+type maxSingleTransferRule struct{}
+
+func (maxSingleTransferRule) Name() string { return "max_single_transfer" }
+
+func (r maxSingleTransferRule) Check(ctx context.Context, c TransferCheck) error {
+	if c.Limits.MaxSingleTransfer > 0 && c.Amount > c.Limits.MaxSingleTransfer {
+		return &errs.LimitError{Rule: r.Name(), Limit: c.Limits.MaxSingleTransfer}
+	}
+	return nil
+}
+
+type outgoingTotalRule struct {
+	name  string
+	limit func(entity.TransferLimits) int64
+	since func(time.Time) time.Time
+}
+
+func (r outgoingTotalRule) Name() string { return r.name }
+
+func (r outgoingTotalRule) Check(ctx context.Context, c TransferCheck) error {
+	limit := r.limit(c.Limits)
+	if limit <= 0 {
+		return nil
+	}
+
+	stats, err := c.Outgoing(r.since(c.Now))
+	if err != nil {
+		return err
+	}
+	if stats.Amount+c.Amount > limit {
+		return &errs.LimitError{Rule: r.name, Limit: limit}
+	}
+	return nil
+}
+
+type hourlyVelocityRule struct{}
+
+func (hourlyVelocityRule) Name() string { return "max_transfers_per_hour" }
+
+func (r hourlyVelocityRule) Check(ctx context.Context, c TransferCheck) error {
+	limit := c.Limits.MaxTransfersPerHour
+	if limit <= 0 {
+		return nil
+	}
+
+	stats, err := c.Outgoing(c.Now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if stats.Count >= limit {
+		return &errs.LimitError{Rule: r.Name(), Limit: int64(limit)}
+	}
+	return nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// SetTransferLimits sets the limits that apply to accounts without limits of
+// their own, by currency: the same number of minor units is worth very
+// different amounts in, say, JPY and KWD. Accounts in a currency global has
+// no limits for have no global limits.
+func (s *Depository) SetTransferLimits(global map[string]entity.TransferLimits) {
+	s.limits = make(map[string]entity.TransferLimits, len(global))
+	for currency, l := range global {
+		l.Number = 0
+		s.limits[currency] = l
+	}
+}
+
+// SetTransferRules replaces the rules transfers are checked against.
+func (s *Depository) SetTransferRules(rules ...TransferRule) {
+	s.rules = NewRulesEngine(rules...)
+}
+
+// checkTransferRules makes the repository run the transfer rules for tr, a
+// transfer out of the account from, once it has locked that account. Checked
+// any earlier, concurrent transfers would all pass the limits on what the
+// account sent before.
+func (s *Depository) checkTransferRules(ctx context.Context, tr *entity.Transfer, from, to int64) error {
+	debit := tr.Entries[0]
+	limits, err := s.effectiveLimits(ctx, from, debit.Currency)
+	if err != nil {
+		return err
+	}
+
+	tr.Check = func(outgoing func(since time.Time) (entity.TransferStats, error)) (err error) {
+		ctx, end := startSpan(ctx, "Depository.checkTransferRules")
+		defer func() { end(err) }()
+
+		return s.rules.Evaluate(ctx, TransferCheck{
+			From:     from,
+			To:       to,
+			Amount:   -debit.Amount,
+			Currency: debit.Currency,
+			Limits:   limits,
+			Now:      time.Now().UTC(),
+			stats: func(since time.Time) (entity.TransferStats, error) {
+				stats, err := outgoing(since)
+				if err != nil {
+					return stats, fmt.Errorf("cannot get outgoing transfers: %w", err)
+				}
+				return stats, nil
+			},
+		})
+	}
+
+	return nil
+}
+
+// effectiveLimits returns the limits of the account number, which holds
+// currency.
+func (s *Depository) effectiveLimits(ctx context.Context, number int64, currency string) (entity.TransferLimits, error) {
+	own, err := s.repo.GetTransferLimits(ctx, number)
+	if err != nil {
+		return entity.TransferLimits{}, fmt.Errorf("cannot get transfer limits: %w", err)
+	}
+	return s.limits[currency].Override(*own), nil
+}
+
+func (s *Depository) GetTransferLimits(ctx context.Context, req param.GetTransferLimitsRequest) (resp param.TransferLimitsResponse, err error) {
+	ctx, end := startSpan(ctx, "Depository.GetTransferLimits")
+	defer func() { end(err) }()
+
+	acc, err := s.repo.GetAccountByNumber(ctx, req.Number)
+	if err != nil {
+		return param.TransferLimitsResponse{}, err
+	}
+	own, err := s.repo.GetTransferLimits(ctx, req.Number)
+	if err != nil {
+		return param.TransferLimitsResponse{}, fmt.Errorf("cannot get transfer limits: %w", err)
+	}
+
+	global := s.limits[acc.Currency]
+	return param.TransferLimitsResponse{
+		Currency:  acc.Currency,
+		Account:   *own,
+		Global:    global,
+		Effective: global.Override(*own),
+	}, nil
+}
+
+// UpdateTransferLimits replaces the limits of an account. Zero limits fall
+// back to the global ones.
+func (s *Depository) UpdateTransferLimits(ctx context.Context, req param.UpdateTransferLimitsRequest) (resp param.TransferLimitsResponse, err error) {
+	ctx, end := startSpan(ctx, "Depository.UpdateTransferLimits")
+	defer func() { end(err) }()
+
+	if req.MaxSingleTransfer < 0 || req.DailyOutgoing < 0 || req.MonthlyOutgoing < 0 || req.MaxTransfersPerHour < 0 {
+		return param.TransferLimitsResponse{}, fmt.Errorf("%w: limits cannot be negative", errs.ErrInvalidRequest)
+	}
+	acc, err := s.repo.GetAccountByNumber(ctx, req.Number)
+	if err != nil {
+		return param.TransferLimitsResponse{}, err
+	}
+
+	own := entity.TransferLimits{
+		Number:              req.Number,
+		MaxSingleTransfer:   req.MaxSingleTransfer,
+		DailyOutgoing:       req.DailyOutgoing,
+		MonthlyOutgoing:     req.MonthlyOutgoing,
+		MaxTransfersPerHour: req.MaxTransfersPerHour,
+	}
+	if err := s.repo.SaveTransferLimits(ctx, &own); err != nil {
+		return param.TransferLimitsResponse{}, fmt.Errorf("cannot save transfer limits: %w", err)
+	}
+
+	global := s.limits[acc.Currency]
+	return param.TransferLimitsResponse{
+		Currency:  acc.Currency,
+		Account:   own,
+		Global:    global,
+		Effective: global.Override(own),
+	}, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+func TestTransferRulesRejectWithRuleName(t *testing.T) {
+	srv, n := newTestDepository(t, 1000, 0)
+	srv.SetTransferLimits(map[string]entity.TransferLimits{"USD": {MaxSingleTransfer: 100, DailyOutgoing: 150, MaxTransfersPerHour: 5}})
+	ctx := context.Background()
+
+	tests := []struct {
+		amount int64
+		rule   string
+	}{
+		{amount: 101, rule: "max_single_transfer"},
+		{amount: 100},
+		{amount: 60, rule: "daily_outgoing"},
+		{amount: 50},
+	}
+	for _, tt := range tests {
+		_, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: tt.amount})
+		if tt.rule == "" {
+			if err != nil {
+				t.Fatalf("unexpected error while transferring %d: %s", tt.amount, err.Error())
+			}
+			continue
+		}
+
+		var limitErr *errs.LimitError
+		if !errors.As(err, &limitErr) || !errors.Is(err, errs.ErrLimitExceeded) {
+			t.Fatalf("expected a limit error transferring %d, but got %v", tt.amount, err)
+		}
+		if limitErr.Rule != tt.rule {
+			t.Errorf("expected rule %s, but got %s", tt.rule, limitErr.Rule)
+		}
+	}
+}
+
+func TestAccountLimitsOverrideGlobalLimits(t *testing.T) {
+	srv, n := newTestDepository(t, 1000, 0)
+	srv.SetTransferLimits(map[string]entity.TransferLimits{"USD": {MaxSingleTransfer: 100, MaxTransfersPerHour: 1}})
+	ctx := context.Background()
+
+	resp, err := srv.UpdateTransferLimits(ctx, param.UpdateTransferLimitsRequest{Number: n[0], MaxSingleTransfer: 500})
+	if err != nil {
+		t.Fatalf("unexpected error while updating limits: %s", err.Error())
+	}
+	if resp.Effective.MaxSingleTransfer != 500 || resp.Effective.MaxTransfersPerHour != 1 {
+		t.Errorf("expected the account limit to override only max_single_transfer, but got %+v", resp.Effective)
+	}
+
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 300}); err != nil {
+		t.Fatalf("unexpected error while transferring: %s", err.Error())
+	}
+	var limitErr *errs.LimitError
+	_, err = srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 1})
+	if !errors.As(err, &limitErr) || limitErr.Rule != "max_transfers_per_hour" {
+		t.Errorf("expected the global hourly limit to still apply, but got %v", err)
+	}
+}
+
+func TestGlobalLimitsApplyByCurrency(t *testing.T) {
+	srv, _ := newTestDepository(t)
+	srv.SetTransferLimits(map[string]entity.TransferLimits{"USD": {MaxSingleTransfer: 100}, "JPY": {MaxSingleTransfer: 10000}})
+	ctx := context.Background()
+
+	accounts := make(map[string][]int64)
+	for _, currency := range []string{"USD", "USD", "JPY", "JPY"} {
+		acc, err := srv.CreateAccount(ctx, param.CreateAccountRequest{Password: "mypassword", Balance: 100000, Currency: currency})
+		if err != nil {
+			t.Fatalf("unexpected error while creating account: %s", err.Error())
+		}
+		accounts[currency] = append(accounts[currency], acc.Number)
+	}
+
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: accounts["JPY"][0], ToAccount: accounts["JPY"][1], Amount: 5000}); err != nil {
+		t.Errorf("unexpected error while transferring 5000 JPY: %s", err.Error())
+	}
+	_, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: accounts["USD"][0], ToAccount: accounts["USD"][1], Amount: 5000})
+	if !errors.Is(err, errs.ErrLimitExceeded) {
+		t.Errorf("expected 5000 USD to exceed the USD limit, but got %v", err)
+	}
+}
+
+func TestConcurrentTransfersStayWithinDailyLimit(t *testing.T) {
+	srv, n := newTestDepository(t, 1000, 0)
+	srv.SetTransferLimits(map[string]entity.TransferLimits{"USD": {DailyOutgoing: 150}})
+	ctx := context.Background()
+
+	var succeeded int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 50}); err == nil {
+				atomic.AddInt64(&succeeded, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 3 {
+		t.Errorf("expected 3 transfers within the daily limit, but %d succeeded", succeeded)
+	}
+}
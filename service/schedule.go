@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	defaultMaxAttempts  = 3
+	defaultRetryBackoff = 5 * time.Minute
+)
+
+func (s *Depository) CreateScheduledTransfer(ctx context.Context, req param.ScheduleTransferRequest) (param.ScheduledTransferResponse, error) {
+	now := time.Now().UTC()
+	st := &entity.ScheduledTransfer{
+		ID:          entity.NewID(),
+		FromAccount: req.Number,
+		Status:      entity.ScheduleActive,
+		CreatedAt:   now,
+	}
+
+	if err := s.applyScheduleRequest(ctx, st, req, now); err != nil {
+		return param.ScheduledTransferResponse{}, err
+	}
+
+	if err := s.repo.CreateScheduledTransfer(ctx, st); err != nil {
+		return param.ScheduledTransferResponse{}, fmt.Errorf("cannot create scheduled transfer: %w", err)
+	}
+
+	return param.ScheduledTransferResponse{Schedule: *st}, nil
+}
+
+func (s *Depository) GetScheduledTransfer(ctx context.Context, req param.GetScheduledTransferRequest) (param.ScheduledTransferResponse, error) {
+	st, err := s.ownedSchedule(ctx, req.Number, req.ID)
+	if err != nil {
+		return param.ScheduledTransferResponse{}, err
+	}
+
+	return param.ScheduledTransferResponse{Schedule: *st}, nil
+}
+
+func (s *Depository) ListScheduledTransfers(ctx context.Context, req param.ListScheduledTransfersRequest) (param.ListScheduledTransfersResponse, error) {
+	schedules, err := s.repo.ListScheduledTransfers(ctx, req.Number)
+	if err != nil {
+		return param.ListScheduledTransfersResponse{}, fmt.Errorf("cannot list scheduled transfers: %w", err)
+	}
+
+	return param.ListScheduledTransfersResponse{Schedules: schedules}, nil
+}
+
+// UpdateScheduledTransfer replaces the terms of a schedule. The next run is
+// computed again from the new start and frequency.
+func (s *Depository) UpdateScheduledTransfer(ctx context.Context, req param.ScheduleTransferRequest) (param.ScheduledTransferResponse, error) {
+	st, err := s.ownedSchedule(ctx, req.Number, req.ID)
+	if err != nil {
+		return param.ScheduledTransferResponse{}, err
+	}
+	if st.Status == entity.ScheduleCompleted || st.Status == entity.ScheduleFailed || st.Status == entity.ScheduleCancelled {
+		return param.ScheduledTransferResponse{}, fmt.Errorf("%w: schedule is %s", errs.ErrInvalidSchedule, st.Status)
+	}
+
+	if err := s.applyScheduleRequest(ctx, st, req, time.Now().UTC()); err != nil {
+		return param.ScheduledTransferResponse{}, err
+	}
+
+	if err := s.repo.UpdateScheduledTransfer(ctx, st); err != nil {
+		return param.ScheduledTransferResponse{}, fmt.Errorf("cannot update scheduled transfer: %w", err)
+	}
+
+	return param.ScheduledTransferResponse{Schedule: *st}, nil
+}
+
+func (s *Depository) DeleteScheduledTransfer(ctx context.Context, req param.GetScheduledTransferRequest) error {
+	if _, err := s.ownedSchedule(ctx, req.Number, req.ID); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteScheduledTransfer(ctx, req.ID); err != nil {
+		return fmt.Errorf("cannot delete scheduled transfer: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Depository) PauseScheduledTransfer(ctx context.Context, req param.GetScheduledTransferRequest) (param.ScheduledTransferResponse, error) {
+	st, err := s.ownedSchedule(ctx, req.Number, req.ID)
+	if err != nil {
+		return param.ScheduledTransferResponse{}, err
+	}
+	if st.Status != entity.ScheduleActive {
+		return param.ScheduledTransferResponse{}, fmt.Errorf("%w: schedule is %s", errs.ErrInvalidSchedule, st.Status)
+	}
+
+	st.Status = entity.SchedulePaused
+	st.UpdatedAt = time.Now().UTC()
+	if err := s.repo.UpdateScheduledTransfer(ctx, st); err != nil {
+		return param.ScheduledTransferResponse{}, fmt.Errorf("cannot pause scheduled transfer: %w", err)
+	}
+
+	return param.ScheduledTransferResponse{Schedule: *st}, nil
+}
+
+// ResumeScheduledTransfer reactivates a paused schedule. Occurrences missed
+// while it was paused are skipped.
+func (s *Depository) ResumeScheduledTransfer(ctx context.Context, req param.GetScheduledTransferRequest) (param.ScheduledTransferResponse, error) {
+	st, err := s.ownedSchedule(ctx, req.Number, req.ID)
+	if err != nil {
+		return param.ScheduledTransferResponse{}, err
+	}
+	if st.Status != entity.SchedulePaused {
+		return param.ScheduledTransferResponse{}, fmt.Errorf("%w: schedule is %s", errs.ErrInvalidSchedule, st.Status)
+	}
+
+	now := time.Now().UTC()
+	st.Status = entity.ScheduleActive
+	st.Attempts = 0
+	if st.NextRunAt.Before(now) && st.Frequency != entity.FrequencyOnce {
+		if err := advanceSchedule(st, now); err != nil {
+			return param.ScheduledTransferResponse{}, err
+		}
+	}
+	st.NextAttemptAt = st.NextRunAt
+	st.UpdatedAt = now
+
+	if err := s.repo.UpdateScheduledTransfer(ctx, st); err != nil {
+		return param.ScheduledTransferResponse{}, fmt.Errorf("cannot resume scheduled transfer: %w", err)
+	}
+
+	return param.ScheduledTransferResponse{Schedule: *st}, nil
+}
+
+func (s *Depository) ListScheduledTransferExecutions(ctx context.Context, req param.GetScheduledTransferRequest) (param.ListScheduledTransferExecutionsResponse, error) {
+	if _, err := s.ownedSchedule(ctx, req.Number, req.ID); err != nil {
+		return param.ListScheduledTransferExecutionsResponse{}, err
+	}
+
+	executions, err := s.repo.ListScheduledTransferExecutions(ctx, req.ID)
+	if err != nil {
+		return param.ListScheduledTransferExecutionsResponse{}, fmt.Errorf("cannot list executions: %w", err)
+	}
+
+	return param.ListScheduledTransferExecutionsResponse{Executions: executions}, nil
+}
+
+// ownedSchedule returns the schedule with id if it moves money out of the
+// account number; schedules of other accounts are reported as not found.
+func (s *Depository) ownedSchedule(ctx context.Context, number int64, id string) (*entity.ScheduledTransfer, error) {
+	st, err := s.repo.GetScheduledTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if st.FromAccount != number {
+		return nil, errs.ErrScheduleNotFound
+	}
+
+	return st, nil
+}
+
+// applyScheduleRequest validates req and copies it onto st, computing the
+// first run.
+func (s *Depository) applyScheduleRequest(ctx context.Context, st *entity.ScheduledTransfer, req param.ScheduleTransferRequest, now time.Time) error {
+	if req.Amount <= 0 {
+		return errs.ErrInvalidAmount
+	}
+	if req.ToAccount == st.FromAccount {
+		return errs.ErrSameAccount
+	}
+	if len(req.Reference) > entity.MaxReferenceLength {
+		return fmt.Errorf("%w: reference is longer than %d characters", errs.ErrInvalidRequest, entity.MaxReferenceLength)
+	}
+
+	from, err := s.repo.GetAccountByNumber(ctx, st.FromAccount)
+	if err != nil {
+		return err
+	}
+	if _, err := s.repo.GetAccountByNumber(ctx, req.ToAccount); err != nil {
+		return err
+	}
+	if req.Currency != "" && req.Currency != from.Currency {
+		return fmt.Errorf("%w: %s", errs.ErrCurrencyMismatch, req.Currency)
+	}
+
+	st.ToAccount = req.ToAccount
+	st.Amount = req.Amount
+	st.Currency = from.Currency
+	st.Reference = req.Reference
+	st.Frequency = req.Frequency
+	st.CronExpression = req.CronExpression
+	st.StartAt = req.StartAt.UTC()
+	st.EndAt = req.EndAt.UTC()
+	st.MaxAttempts = req.MaxAttempts
+	st.RetryBackoff = defaultRetryBackoff
+	st.Attempts = 0
+	st.UpdatedAt = now
+
+	if req.EndAt.IsZero() {
+		st.EndAt = time.Time{}
+	}
+	if st.StartAt.IsZero() || st.StartAt.Before(now) {
+		st.StartAt = now
+	}
+	if st.MaxAttempts <= 0 {
+		st.MaxAttempts = defaultMaxAttempts
+	}
+	if req.RetryBackoff != "" {
+		if st.RetryBackoff, err = time.ParseDuration(req.RetryBackoff); err != nil || st.RetryBackoff <= 0 {
+			return fmt.Errorf("%w: invalid retry backoff %q", errs.ErrInvalidSchedule, req.RetryBackoff)
+		}
+	}
+
+	first, err := firstOccurrence(st)
+	if err != nil {
+		return err
+	}
+	if !st.EndAt.IsZero() && first.After(st.EndAt) {
+		return fmt.Errorf("%w: schedule ends before its first run", errs.ErrInvalidSchedule)
+	}
+	st.NextRunAt = first
+	st.NextAttemptAt = first
+
+	// runs are not asked for a code, so setting up the schedule is where the
+	// second factor of every run it makes is checked
+	return s.checkTransferMFA(ctx, st.FromAccount, st.Amount, req.TOTPCode)
+}
+
+func firstOccurrence(st *entity.ScheduledTransfer) (time.Time, error) {
+	switch st.Frequency {
+	case entity.FrequencyOnce, entity.FrequencyDaily, entity.FrequencyWeekly, entity.FrequencyMonthly:
+		if st.CronExpression != "" {
+			return time.Time{}, fmt.Errorf("%w: cron expression requires the cron frequency", errs.ErrInvalidSchedule)
+		}
+		return st.StartAt, nil
+	case entity.FrequencyCron:
+		sched, err := cron.ParseStandard(st.CronExpression)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %v", errs.ErrInvalidSchedule, err)
+		}
+		return sched.Next(st.StartAt.Add(-time.Second)).UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("%w: unknown frequency %q", errs.ErrInvalidSchedule, st.Frequency)
+	}
+}
+
+// nextOccurrence returns the occurrence after prev, or the zero time for
+// one-off schedules.
+func nextOccurrence(st *entity.ScheduledTransfer, prev time.Time) (time.Time, error) {
+	switch st.Frequency {
+	case entity.FrequencyDaily:
+		return prev.AddDate(0, 0, 1), nil
+	case entity.FrequencyWeekly:
+		return prev.AddDate(0, 0, 7), nil
+	case entity.FrequencyMonthly:
+		return addMonth(prev, st.StartAt.Day()), nil
+	case entity.FrequencyCron:
+		sched, err := cron.ParseStandard(st.CronExpression)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %v", errs.ErrInvalidSchedule, err)
+		}
+		return sched.Next(prev).UTC(), nil
+	default:
+		return time.Time{}, nil
+	}
+}
+
+// addMonth moves t to day of the following month, clamped to the last day of
+// that month, so a schedule starting on the 31st does not drift.
+func addMonth(t time.Time, day int) time.Time {
+	firstOfNext := time.Date(t.Year(), t.Month()+1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := firstOfNext.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return firstOfNext.AddDate(0, 0, day-1)
+}
+
+// advanceSchedule moves st to its first occurrence after now, skipping the
+// ones that were missed, and completes it when there is none left.
+func advanceSchedule(st *entity.ScheduledTransfer, now time.Time) error {
+	next, err := nextOccurrence(st, st.NextRunAt)
+	for err == nil && !next.IsZero() && !next.After(now) {
+		next, err = nextOccurrence(st, next)
+	}
+	if err != nil {
+		return err
+	}
+
+	st.Attempts = 0
+	if next.IsZero() || (!st.EndAt.IsZero() && next.After(st.EndAt)) {
+		st.Status = entity.ScheduleCompleted
+		return nil
+	}
+
+	st.NextRunAt = next
+	st.NextAttemptAt = next
+	return nil
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/logging"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+const schedulerBatchSize = 100
+
+// Scheduler executes due scheduled transfers through
+// Depository.TransferAmount. Every occurrence uses its own idempotency key, so
+// it moves money at most once even when several instances run a scheduler.
+type Scheduler struct {
+	depository *Depository
+	interval   time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewScheduler(d *Depository, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		depository: d,
+		interval:   interval,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start runs the scheduler in the background until Stop is called.
+func (s *Scheduler) Start() {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := s.RunDue(context.Background(), time.Now().UTC()); err != nil {
+				slog.Error("cannot run scheduled transfers", "error", err)
+			}
+
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler and waits for the running batch to finish.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// RunDue executes every schedule that is due at now.
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) error {
+	due, err := s.depository.repo.ListDueScheduledTransfers(ctx, now, schedulerBatchSize)
+	if err != nil {
+		return fmt.Errorf("cannot list due scheduled transfers: %w", err)
+	}
+
+	for i := range due {
+		// every execution is traced like a request of its own
+		execCtx, end := startSpan(logging.WithRequestID(ctx, entity.NewID()), "Scheduler.execute")
+		err := s.execute(execCtx, &due[i], now)
+		if err != nil {
+			slog.ErrorContext(execCtx, "cannot execute scheduled transfer", "schedule_id", due[i].ID, "error", err)
+		}
+		end(err)
+	}
+
+	return nil
+}
+
+func (s *Scheduler) execute(ctx context.Context, st *entity.ScheduledTransfer, now time.Time) error {
+	resp, transferErr := s.depository.TransferAmount(ctx, param.TransferAmountRequest{
+		FromAccount:    st.FromAccount,
+		ToAccount:      st.ToAccount,
+		Amount:         st.Amount,
+		Currency:       st.Currency,
+		Reference:      st.Reference,
+		IdempotencyKey: fmt.Sprintf("schedule:%s:%d", st.ID, st.NextRunAt.Unix()),
+		Scheduled:      true,
+	})
+	if errors.Is(transferErr, errs.ErrIdempotencyKeyInProgress) || errors.Is(transferErr, errs.ErrIdempotencyKeyExists) {
+		// another instance is running this occurrence and records its outcome
+		slog.InfoContext(ctx, "scheduled transfer already being executed", "schedule_id", st.ID)
+		return nil
+	}
+
+	st.Attempts++
+	exec := &entity.ScheduledTransferExecution{
+		ScheduleID: st.ID,
+		RunAt:      st.NextRunAt,
+		Attempt:    st.Attempts,
+		Status:     entity.ExecutionSucceeded,
+		TransferID: resp.TransferID,
+		ExecutedAt: now,
+	}
+	if transferErr != nil {
+		exec.Status = entity.ExecutionFailed
+		exec.Error = transferErr.Error()
+	}
+	if err := s.depository.repo.CreateScheduledTransferExecution(ctx, exec); err != nil {
+		return fmt.Errorf("cannot record execution: %w", err)
+	}
+
+	switch {
+	case transferErr == nil:
+		st.LastRunAt = now
+		if err := advanceSchedule(st, now); err != nil {
+			return err
+		}
+	case st.Attempts >= st.MaxAttempts:
+		// give up on this occurrence; a one-off schedule has nothing left to run
+		if st.Frequency == entity.FrequencyOnce {
+			st.Status = entity.ScheduleFailed
+		} else if err := advanceSchedule(st, now); err != nil {
+			return err
+		}
+	default:
+		st.NextAttemptAt = now.Add(st.RetryBackoff * time.Duration(1<<(st.Attempts-1)))
+	}
+	st.UpdatedAt = now
+
+	// keep a pause or cancellation that happened while the transfer was
+	// running
+	current, err := s.depository.repo.GetScheduledTransfer(ctx, st.ID)
+	if errors.Is(err, errs.ErrScheduleNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current.Status == entity.ScheduleCancelled || (current.Status == entity.SchedulePaused && st.Status == entity.ScheduleActive) {
+		st.Status = current.Status
+	}
+
+	return s.depository.repo.UpdateScheduledTransfer(ctx, st)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+func TestSchedulerRunsRecurringTransfer(t *testing.T) {
+	srv, n := newTestDepository(t, 100, 0)
+	ctx := context.Background()
+	scheduler := NewScheduler(srv, time.Minute)
+
+	created, err := srv.CreateScheduledTransfer(ctx, param.ScheduleTransferRequest{Number: n[0], ToAccount: n[1], Amount: 10, Frequency: entity.FrequencyDaily})
+	if err != nil {
+		t.Fatalf("unexpected error while scheduling: %s", err.Error())
+	}
+	start := created.Schedule.NextRunAt
+
+	for _, now := range []time.Time{start.Add(time.Minute), start.Add(2 * time.Minute), start.Add(24*time.Hour + time.Minute)} {
+		if err := scheduler.RunDue(ctx, now); err != nil {
+			t.Fatalf("unexpected error while running schedules: %s", err.Error())
+		}
+	}
+
+	account, _ := srv.GetAccountByNumber(ctx, param.GetAccountByNumberRequest{Number: n[1]})
+	if account.Balance != 20 {
+		t.Errorf("expected two occurrences to be transferred, but got balance %d", account.Balance)
+	}
+
+	got, _ := srv.GetScheduledTransfer(ctx, param.GetScheduledTransferRequest{Number: n[0], ID: created.Schedule.ID})
+	if want := start.AddDate(0, 0, 2); !got.Schedule.NextRunAt.Equal(want) {
+		t.Errorf("expected next run at %s, but got %s", want, got.Schedule.NextRunAt)
+	}
+}
+
+func TestSchedulerRetriesThenFails(t *testing.T) {
+	srv, n := newTestDepository(t, 5, 0)
+	ctx := context.Background()
+	scheduler := NewScheduler(srv, time.Minute)
+
+	created, err := srv.CreateScheduledTransfer(ctx, param.ScheduleTransferRequest{
+		Number:       n[0],
+		ToAccount:    n[1],
+		Amount:       10,
+		Frequency:    entity.FrequencyOnce,
+		MaxAttempts:  2,
+		RetryBackoff: "1m",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error while scheduling: %s", err.Error())
+	}
+	req := param.GetScheduledTransferRequest{Number: n[0], ID: created.Schedule.ID}
+	now := created.Schedule.NextRunAt
+
+	_ = scheduler.RunDue(ctx, now)
+	got, _ := srv.GetScheduledTransfer(ctx, req)
+	if got.Schedule.Status != entity.ScheduleActive || !got.Schedule.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("expected a retry in a minute, but got %+v", got.Schedule)
+	}
+
+	_ = scheduler.RunDue(ctx, now.Add(time.Minute))
+	got, _ = srv.GetScheduledTransfer(ctx, req)
+	if got.Schedule.Status != entity.ScheduleFailed {
+		t.Errorf("expected schedule to fail after two attempts, but got %s", got.Schedule.Status)
+	}
+
+	executions, _ := srv.ListScheduledTransferExecutions(ctx, req)
+	if len(executions.Executions) != 2 {
+		t.Errorf("expected 2 executions, but got %d", len(executions.Executions))
+	}
+}
+
+func TestSchedulerSkipsOccurrenceRunByAnotherInstance(t *testing.T) {
+	srv, n := newTestDepository(t, 100, 0)
+	ctx := context.Background()
+	scheduler := NewScheduler(srv, time.Minute)
+
+	created, err := srv.CreateScheduledTransfer(ctx, param.ScheduleTransferRequest{Number: n[0], ToAccount: n[1], Amount: 10, Frequency: entity.FrequencyDaily})
+	if err != nil {
+		t.Fatalf("unexpected error while scheduling: %s", err.Error())
+	}
+	start := created.Schedule.NextRunAt
+
+	// the other instance has reserved the occurrence and is still moving money
+	key := fmt.Sprintf("schedule:%s:%d", created.Schedule.ID, start.Unix())
+	hash, _ := requestHash(param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 10, Currency: created.Schedule.Currency, IdempotencyKey: key, Scheduled: true})
+	rec := &entity.IdempotencyRecord{FromAccount: n[0], Key: key, RequestHash: hash, CreatedAt: time.Now().UTC()}
+	if err := srv.repo.CreateIdempotencyRecord(ctx, rec); err != nil {
+		t.Fatalf("unexpected error while reserving key: %s", err.Error())
+	}
+
+	if err := scheduler.RunDue(ctx, start.Add(time.Minute)); err != nil {
+		t.Fatalf("unexpected error while running schedules: %s", err.Error())
+	}
+
+	req := param.GetScheduledTransferRequest{Number: n[0], ID: created.Schedule.ID}
+	got, _ := srv.GetScheduledTransfer(ctx, req)
+	if got.Schedule.Attempts != 0 || !got.Schedule.NextRunAt.Equal(start) {
+		t.Errorf("expected the schedule to be left to the other instance, but got %+v", got.Schedule)
+	}
+	executions, _ := srv.ListScheduledTransferExecutions(ctx, req)
+	if len(executions.Executions) != 0 {
+		t.Errorf("expected no execution to be recorded, but got %d", len(executions.Executions))
+	}
+}
+
+func TestAddMonthClampsToMonthEnd(t *testing.T) {
+	jan := time.Date(2023, time.January, 31, 9, 0, 0, 0, time.UTC)
+
+	feb := addMonth(jan, 31)
+	if want := time.Date(2023, time.February, 28, 9, 0, 0, 0, time.UTC); !feb.Equal(want) {
+		t.Errorf("expected %s, but got %s", want, feb)
+	}
+	if mar, want := addMonth(feb, 31), time.Date(2023, time.March, 31, 9, 0, 0, 0, time.UTC); !mar.Equal(want) {
+		t.Errorf("expected %s, but got %s", want, mar)
+	}
+}
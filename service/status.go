@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+)
+
+// ChangeAccountStatus moves an account to any status its current status can
+// change to.
+func (s *Depository) ChangeAccountStatus(ctx context.Context, req param.ChangeAccountStatusRequest) (err error) {
+	ctx, end := startSpan(ctx, "Depository.ChangeAccountStatus")
+	defer func() { end(err) }()
+
+	return s.changeAccountStatus(ctx, req.Number, req.Status, req.Reason, req.Actor)
+}
+
+// FreezeAccount stops all money from moving in or out of an account until it
+// is unfrozen, and pauses its scheduled transfers. Unfreezing leaves them
+// paused for the owner to resume.
+func (s *Depository) FreezeAccount(ctx context.Context, req param.FreezeAccountRequest) (err error) {
+	ctx, end := startSpan(ctx, "Depository.FreezeAccount")
+	defer func() { end(err) }()
+
+	return s.changeAccountStatus(ctx, req.Number, entity.StatusFrozen, req.Reason, req.Actor)
+}
+
+func (s *Depository) UnfreezeAccount(ctx context.Context, req param.FreezeAccountRequest) (err error) {
+	ctx, end := startSpan(ctx, "Depository.UnfreezeAccount")
+	defer func() { end(err) }()
+
+	acc, err := s.repo.GetAccountByNumber(ctx, req.Number)
+	if err != nil {
+		return fmt.Errorf("cannot unfreeze account: %w", err)
+	}
+	if acc.Status != entity.StatusFrozen {
+		return fmt.Errorf("%w: account is %s", errs.ErrInvalidStatusChange, acc.Status)
+	}
+
+	return s.changeAccountStatus(ctx, req.Number, entity.StatusActive, req.Reason, req.Actor)
+}
+
+// CloseAccount closes an account whose balance is zero. The account and its
+// history are kept, but no money can move in or out of it again, it can no
+// longer log in and its scheduled transfers are cancelled.
+func (s *Depository) CloseAccount(ctx context.Context, req param.CloseAccountRequest) (err error) {
+	ctx, end := startSpan(ctx, "Depository.CloseAccount")
+	defer func() { end(err) }()
+
+	return s.changeAccountStatus(ctx, req.Number, entity.StatusClosed, req.Reason, req.Actor)
+}
+
+// GetAccountStatusHistory returns the status changes of an account, oldest
+// first.
+func (s *Depository) GetAccountStatusHistory(ctx context.Context, req param.GetAccountStatusHistoryRequest) (resp param.GetAccountStatusHistoryResponse, err error) {
+	ctx, end := startSpan(ctx, "Depository.GetAccountStatusHistory")
+	defer func() { end(err) }()
+
+	acc, err := s.repo.GetAccountByNumber(ctx, req.Number)
+	if err != nil {
+		return param.GetAccountStatusHistoryResponse{}, fmt.Errorf("cannot get status history: %w", err)
+	}
+	changes, err := s.repo.ListAccountStatusChanges(ctx, req.Number)
+	if err != nil {
+		return param.GetAccountStatusHistoryResponse{}, fmt.Errorf("cannot get status history: %w", err)
+	}
+
+	return param.GetAccountStatusHistoryResponse{Number: acc.Number, Status: acc.Status, Changes: changes}, nil
+}
+
+func (s *Depository) changeAccountStatus(ctx context.Context, number int64, to entity.AccountStatus, reason, actor string) error {
+	if !entity.ValidAccountStatus(to) {
+		return fmt.Errorf("%w: %q", errs.ErrInvalidStatus, to)
+	}
+
+	acc, err := s.repo.GetAccountByNumber(ctx, number)
+	if err != nil {
+		return fmt.Errorf("cannot set status of account: %w", err)
+	}
+	if !acc.Status.CanChangeTo(to) {
+		return fmt.Errorf("%w: %s to %s", errs.ErrInvalidStatusChange, acc.Status, to)
+	}
+	if to == entity.StatusClosed && acc.Balance != 0 {
+		return fmt.Errorf("%w: balance is %d", errs.ErrBalanceNotZero, acc.Balance)
+	}
+
+	change := &entity.AccountStatusChange{
+		ID:        entity.NewID(),
+		Number:    number,
+		From:      acc.Status,
+		To:        to,
+		Reason:    reason,
+		Actor:     actor,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.repo.ChangeAccountStatus(ctx, change); err != nil {
+		return fmt.Errorf("cannot set status of account: %w", err)
+	}
+	slog.InfoContext(ctx, "account status changed", "number", number, "from", change.From, "to", to, "actor", actor, "reason", reason)
+
+	switch to {
+	case entity.StatusFrozen:
+		return s.stopScheduledTransfers(ctx, number, entity.SchedulePaused)
+	case entity.StatusClosed:
+		return s.stopScheduledTransfers(ctx, number, entity.ScheduleCancelled)
+	}
+	return nil
+}
+
+// stopScheduledTransfers moves the schedules sending from an account that
+// are still to run into status.
+func (s *Depository) stopScheduledTransfers(ctx context.Context, number int64, status entity.ScheduleStatus) error {
+	schedules, err := s.repo.ListScheduledTransfers(ctx, number)
+	if err != nil {
+		return fmt.Errorf("cannot list scheduled transfers: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for i := range schedules {
+		st := &schedules[i]
+		if st.Status == status || (st.Status != entity.ScheduleActive && st.Status != entity.SchedulePaused) {
+			continue
+		}
+		st.Status = status
+		st.UpdatedAt = now
+		if err := s.repo.UpdateScheduledTransfer(ctx, st); err != nil {
+			return fmt.Errorf("cannot stop scheduled transfer: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mohamadafzal06/depository/entity"
+	"github.com/mohamadafzal06/depository/errs"
+	"github.com/mohamadafzal06/depository/param"
+	"github.com/mohamadafzal06/depository/repository"
+)
+
+func TestAccountStatusTransitions(t *testing.T) {
+	for _, tt := range []struct {
+		from, to entity.AccountStatus
+		ok       bool
+	}{
+		{entity.StatusPending, entity.StatusActive, true},
+		{entity.StatusActive, entity.StatusDormant, true},
+		{entity.StatusDormant, entity.StatusActive, true},
+		{entity.StatusFrozen, entity.StatusDormant, false},
+		{entity.StatusActive, entity.StatusPending, false},
+		{entity.StatusClosed, entity.StatusActive, false},
+	} {
+		if got := tt.from.CanChangeTo(tt.to); got != tt.ok {
+			t.Errorf("expected %s to %s to be allowed: %t, but got %t", tt.from, tt.to, tt.ok, got)
+		}
+	}
+}
+
+func TestCloseAccountRequiresZeroBalanceAndIsFinal(t *testing.T) {
+	srv, n := newTestDepository(t, 100, 0)
+	ctx := context.Background()
+
+	err := srv.CloseAccount(ctx, param.CloseAccountRequest{Number: n[0], Reason: "moving abroad", Actor: "admin_key"})
+	if !errors.Is(err, errs.ErrBalanceNotZero) {
+		t.Fatalf("expected %v, but got %v", errs.ErrBalanceNotZero, err)
+	}
+
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 100}); err != nil {
+		t.Fatalf("unexpected error while transferring: %s", err.Error())
+	}
+	if err := srv.CloseAccount(ctx, param.CloseAccountRequest{Number: n[0], Reason: "moving abroad", Actor: "admin_key"}); err != nil {
+		t.Fatalf("unexpected error while closing account: %s", err.Error())
+	}
+
+	_, err = srv.Deposit(ctx, param.DepositRequest{Number: n[0], Amount: 10})
+	if !errors.Is(err, errs.ErrAccountClosed) {
+		t.Errorf("expected deposit to fail with %v, but got %v", errs.ErrAccountClosed, err)
+	}
+	err = srv.UnfreezeAccount(ctx, param.FreezeAccountRequest{Number: n[0]})
+	if !errors.Is(err, errs.ErrInvalidStatusChange) {
+		t.Errorf("expected %v, but got %v", errs.ErrInvalidStatusChange, err)
+	}
+
+	history, err := srv.GetAccountStatusHistory(ctx, param.GetAccountStatusHistoryRequest{Number: n[0]})
+	if err != nil {
+		t.Fatalf("unexpected error while getting status history: %s", err.Error())
+	}
+	if history.Status != entity.StatusClosed || len(history.Changes) != 2 {
+		t.Fatalf("expected activation and change to closed, but got %+v", history)
+	}
+	if c := history.Changes[1]; c.From != entity.StatusActive || c.Reason != "moving abroad" || c.Actor != "admin_key" {
+		t.Errorf("unexpected status change %+v", c)
+	}
+}
+
+func TestDormantAccountReceivesButCannotSend(t *testing.T) {
+	srv, n := newTestDepository(t, 100, 100)
+	ctx := context.Background()
+
+	if err := srv.ChangeAccountStatus(ctx, param.ChangeAccountStatusRequest{Number: n[1], Status: entity.StatusDormant, Reason: "no activity"}); err != nil {
+		t.Fatalf("unexpected error while changing status: %s", err.Error())
+	}
+
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 10}); err != nil {
+		t.Errorf("unexpected error while transferring to dormant account: %s", err.Error())
+	}
+	_, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[1], ToAccount: n[0], Amount: 10})
+	if !errors.Is(err, errs.ErrAccountInactive) {
+		t.Errorf("expected %v, but got %v", errs.ErrAccountInactive, err)
+	}
+}
+
+// freezingRepository freezes an account just before a transfer reaches the
+// repository, after the service has checked the account status.
+type freezingRepository struct {
+	repository.Repository
+	number int64
+}
+
+func (r *freezingRepository) TransferAmount(ctx context.Context, tr *entity.Transfer) error {
+	err := r.ChangeAccountStatus(ctx, &entity.AccountStatusChange{Number: r.number, From: entity.StatusActive, To: entity.StatusFrozen, Actor: "admin_key"})
+	if err != nil {
+		return err
+	}
+	return r.Repository.TransferAmount(ctx, tr)
+}
+
+func TestTransferRechecksStatusInRepository(t *testing.T) {
+	for _, side := range []string{"sender", "receiver"} {
+		srv, n := newTestDepository(t, 100, 100)
+		ctx := context.Background()
+
+		frozen := n[0]
+		if side == "receiver" {
+			frozen = n[1]
+		}
+		srv.repo = &freezingRepository{Repository: srv.repo, number: frozen}
+
+		_, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 30})
+		if !errors.Is(err, errs.ErrAccountFrozen) {
+			t.Errorf("%s frozen: expected %v, but got %v", side, errs.ErrAccountFrozen, err)
+		}
+		acc, err := srv.repo.GetAccountByNumber(ctx, n[0])
+		if err != nil {
+			t.Fatalf("unexpected error while getting account: %s", err.Error())
+		}
+		if acc.Balance != 100 {
+			t.Errorf("%s frozen: expected balance 100, but got %d", side, acc.Balance)
+		}
+	}
+}
+
+func TestNewAccountIsPendingUntilFunded(t *testing.T) {
+	srv, n := newTestDepository(t, 100, 0)
+	ctx := context.Background()
+
+	acc, err := srv.GetAccountByNumber(ctx, param.GetAccountByNumberRequest{Number: n[1]})
+	if err != nil {
+		t.Fatalf("unexpected error while getting account: %s", err.Error())
+	}
+	if acc.Status != entity.StatusPending {
+		t.Fatalf("expected unfunded account to be %s, but got %s", entity.StatusPending, acc.Status)
+	}
+	_, err = srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[1], ToAccount: n[0], Amount: 10})
+	if !errors.Is(err, errs.ErrAccountInactive) {
+		t.Errorf("expected %v, but got %v", errs.ErrAccountInactive, err)
+	}
+
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[0], ToAccount: n[1], Amount: 40}); err != nil {
+		t.Fatalf("unexpected error while transferring: %s", err.Error())
+	}
+	history, err := srv.GetAccountStatusHistory(ctx, param.GetAccountStatusHistoryRequest{Number: n[1]})
+	if err != nil {
+		t.Fatalf("unexpected error while getting status history: %s", err.Error())
+	}
+	if history.Status != entity.StatusActive || len(history.Changes) != 1 || history.Changes[0].Actor != entity.ActorSystem {
+		t.Fatalf("expected account to be activated by the system, but got %+v", history)
+	}
+	if _, err := srv.TransferAmount(ctx, param.TransferAmountRequest{FromAccount: n[1], ToAccount: n[0], Amount: 10}); err != nil {
+		t.Errorf("unexpected error while transferring from activated account: %s", err.Error())
+	}
+}
+
+func TestFreezeAndClosePauseAndCancelSchedules(t *testing.T) {
+	srv, n := newTestDepository(t, 100, 100)
+	ctx := context.Background()
+
+	created, err := srv.CreateScheduledTransfer(ctx, param.ScheduleTransferRequest{Number: n[0], ToAccount: n[1], Amount: 10, Frequency: entity.FrequencyDaily})
+	if err != nil {
+		t.Fatalf("unexpected error while scheduling: %s", err.Error())
+	}
+	status := func() entity.ScheduleStatus {
+		got, err := srv.GetScheduledTransfer(ctx, param.GetScheduledTransferRequest{Number: n[0], ID: created.Schedule.ID})
+		if err != nil {
+			t.Fatalf("unexpected error while getting schedule: %s", err.Error())
+		}
+		return got.Schedule.Status
+	}
+
+	if err := srv.FreezeAccount(ctx, param.FreezeAccountRequest{Number: n[0], Actor: "admin_key"}); err != nil {
+		t.Fatalf("unexpected error while freezing account: %s", err.Error())
+	}
+	if got := status(); got != entity.SchedulePaused {
+		t.Errorf("expected schedule of frozen account to be %s, but got %s", entity.SchedulePaused, got)
+	}
+
+	if err := srv.UnfreezeAccount(ctx, param.FreezeAccountRequest{Number: n[0], Actor: "admin_key"}); err != nil {
+		t.Fatalf("unexpected error while unfreezing account: %s", err.Error())
+	}
+	if _, err := srv.Withdraw(ctx, param.WithdrawRequest{Number: n[0], Amount: 100}); err != nil {
+		t.Fatalf("unexpected error while withdrawing: %s", err.Error())
+	}
+	if err := srv.CloseAccount(ctx, param.CloseAccountRequest{Number: n[0], Actor: "admin_key"}); err != nil {
+		t.Fatalf("unexpected error while closing account: %s", err.Error())
+	}
+	if got := status(); got != entity.ScheduleCancelled {
+		t.Errorf("expected schedule of closed account to be %s, but got %s", entity.ScheduleCancelled, got)
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var tracer = otel.Tracer("github.com/mohamadafzal06/depository/service")
+
+// startSpan starts a span called name. The returned function ends it and
+// records err, so callers use it as
+//
+//	ctx, end := startSpan(ctx, "Depository.Deposit")
+//	defer func() { end(err) }()
+func startSpan(ctx context.Context, name string) (context.Context, func(err error)) {
+	ctx, span := tracer.Start(ctx, name)
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}